@@ -0,0 +1,231 @@
+package main
+
+// IMPORTS
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+//// BUILD CACHE
+// the build cache makes repeated builds faster, by remembering a hash of all the inputs of every
+// built file. If the inputs of a file didn't change since the last build, and its output still exists,
+// the file is neither rendered nor copied again.
+// The cache lives in the workspaces HIDDEN_DIR, and can be bypassed using 'silvera build --force'.
+// -----------------------------------------------------------------------------------
+
+const (
+	CACHE_VERSION  = "2"             // bump this whenever the build output changes for the same inputs
+	CACHE_MANIFEST = "manifest.json" // the file in the cache directory that records the hashes
+)
+
+// this struct holds the hashes recorded for a single source file.
+type cacheEntry struct {
+	Input  string `json:"input"`  // hash of the source file, its config and its addons
+	Output string `json:"output"` // hash of everything the built file depends on
+}
+
+// this struct holds the state of the build cache during a single build.
+// its methods may be called from multiple goroutines at once.
+type buildCache struct {
+	mutex   sync.Mutex            // guards the maps that are written during the build
+	dir     string                // the directory the cache is stored in
+	force   bool                  // if set, nothing is considered up to date
+	old     map[string]cacheEntry // the entries recorded by the previous build, by relative source path
+	new     map[string]cacheEntry // the entries recorded by this build
+	addons  map[string]string     // hashes of the installed addons, by addon name
+	tmpls   map[string]string     // hashes of the used template files, by path
+	bodies  map[string]bool       // the rendered bodies that are still in use, by input hash
+	enabled bool                  // false if the cache directory could not be created
+}
+
+// hashes the given parts. The parts are length-prefixed, so that different splits never collide.
+func hashParts(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		fmt.Fprintf(h, "%d:", len(part))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashes all files found below the given directory, including their relative paths.
+func hashDir(dir string) string {
+	var parts [][]byte
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		parts = append(parts, []byte(rel), contents)
+		return nil
+	})
+	return hashParts(parts...)
+}
+
+// loads the cache of the previous build from the workspace. A missing or broken manifest
+// simply results in an empty cache.
+func loadBuildCache(force bool) *buildCache {
+	cache := &buildCache{
+		dir:    filepath.Join(WORKING_DIR, HIDDEN_DIR, "cache"),
+		force:  force,
+		old:    make(map[string]cacheEntry),
+		new:    make(map[string]cacheEntry),
+		addons: make(map[string]string),
+		tmpls:  make(map[string]string),
+		bodies: make(map[string]bool),
+	}
+	cache.enabled = os.MkdirAll(cache.dir, 0755) == nil
+
+	if manifest, err := ioutil.ReadFile(filepath.Join(cache.dir, CACHE_MANIFEST)); err == nil {
+		if err := json.Unmarshal(manifest, &cache.old); err != nil {
+			cache.old = make(map[string]cacheEntry)
+		}
+	}
+
+	// hash every installed addon once, so changes to an addon invalidate the files it is used for
+	addon_dirs, _ := ioutil.ReadDir(ADDON_DIR)
+	for _, addon_dir := range addon_dirs {
+		if addon_dir.IsDir() {
+			cache.addons[addon_dir.Name()] = hashDir(filepath.Join(ADDON_DIR, addon_dir.Name()))
+		}
+	}
+	return cache
+}
+
+// returns the hash of a source file, together with everything that affects how it is rendered:
+// the path it is written to, its effective config, the addons enabled by that config,
+// and any extra data the rendering depends on (like the page index).
+func (cache *buildCache) inputHash(src_bytes []byte, outpath string, conf Config, extra ...string) string {
+	conf_bytes, _ := yaml.Marshal(&conf)
+	parts := [][]byte{[]byte(CACHE_VERSION), src_bytes, []byte(outpath), conf_bytes}
+	for _, addon_name := range conf.Addons {
+		parts = append(parts, []byte(cache.addons[addon_name]))
+	}
+	for _, data := range extra {
+		parts = append(parts, []byte(data))
+	}
+	return hashParts(parts...)
+}
+
+// returns the hash of a template file. Each template is only read once per build.
+func (cache *buildCache) templateHash(path string) string {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if hash, ok := cache.tmpls[path]; ok {
+		return hash
+	}
+	contents, _ := ioutil.ReadFile(path)
+	cache.tmpls[path] = hashParts(contents)
+	return cache.tmpls[path]
+}
+
+// returns a hash of the data every page can access through {{.Site}}, including the link graph.
+// if this changes, every page has to be embedded in its template again.
+func siteHash(site *Site) string {
+	type pageSummary struct {
+		Path, URL, Title string
+		Params           map[string]interface{}
+		LastModified     int64 // listings are sorted by this, which is the files mtime for pages without a date
+		Summary          string
+		WordCount        int
+		OutLinks         []string
+	}
+	summaries := make([]pageSummary, len(site.Pages))
+	for i, page := range site.Pages {
+		summaries[i] = pageSummary{page.Path, page.URL, page.Title, page.Params, page.lastModified().UnixNano(), string(page.Summary), page.WordCount, nil}
+		for _, target := range page.outLinks {
+			summaries[i].OutLinks = append(summaries[i].OutLinks, target.relpath)
+		}
+	}
+	data, _ := json.Marshal(summaries)
+	return hashParts(data)
+}
+
+// returns the hash of everything the final html file of the given page depends on.
+func (cache *buildCache) outputHash(page *Page, site_hash string, partials_hash string) string {
+	return hashParts([]byte(page.inputHash), []byte(cache.templateHash(page.template)), []byte(partials_hash), []byte(site_hash))
+}
+
+// reports whether the file at relpath was built from the same inputs before, and its output still exists.
+// output may be empty for files where the input hash is all that matters.
+func (cache *buildCache) isFresh(relpath string, input string, output string, outpath string) bool {
+	if cache.force {
+		return false
+	}
+	entry, ok := cache.old[relpath]
+	if !ok || entry.Input != input || entry.Output != output {
+		return false
+	}
+	_, err := os.Stat(outpath)
+	return err == nil
+}
+
+// returns the html body that was rendered from the inputs with the given hash in a previous build.
+func (cache *buildCache) body(input string) ([]byte, bool) {
+	if cache.force || !cache.enabled {
+		return nil, false
+	}
+	body, err := ioutil.ReadFile(filepath.Join(cache.dir, input+".html"))
+	if err != nil {
+		return nil, false
+	}
+	cache.mutex.Lock()
+	cache.bodies[input] = true
+	cache.mutex.Unlock()
+	return body, true
+}
+
+// stores a freshly rendered html body, so the next build can reuse it.
+func (cache *buildCache) storeBody(input string, body []byte) {
+	if !cache.enabled {
+		return
+	}
+	if ioutil.WriteFile(filepath.Join(cache.dir, input+".html"), body, 0644) == nil {
+		cache.mutex.Lock()
+		cache.bodies[input] = true
+		cache.mutex.Unlock()
+	}
+}
+
+// records that the file at relpath was built successfully from the given inputs.
+func (cache *buildCache) record(relpath string, input string, output string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.new[relpath] = cacheEntry{Input: input, Output: output}
+}
+
+// writes the entries recorded during this build to the manifest, and removes cached bodies
+// that are no longer in use.
+func (cache *buildCache) save() error {
+	if !cache.enabled {
+		return nil
+	}
+	files, err := ioutil.ReadDir(cache.dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name := file.Name()
+		if filepath.Ext(name) == ".html" && !cache.bodies[name[:len(name)-len(".html")]] {
+			os.Remove(filepath.Join(cache.dir, name))
+		}
+	}
+
+	manifest, err := json.MarshalIndent(cache.new, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(cache.dir, CACHE_MANIFEST), manifest, 0644)
+}
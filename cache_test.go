@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuildCache(t *testing.T) {
+	// the pages are dated, as the mtime of undated pages is part of {{.Site}} (see TestBuildCacheListingOrder)
+	dated := "---\ndate: 2022-06-01\n---\n"
+	setupWorkspace(t, "{{range .Site.Pages}}{{.Title}};{{end}}{{.Body}}", map[string]string{
+		"a.md":      dated + "# A\nfirst\n\nsecond paragraph",
+		"b.md":      dated + "# B\nsecond",
+		"image.png": "pixels",
+	})
+	testerr(commandBuild(nil), t)
+
+	// mark the built files, so we can tell whether they were written again
+	mark := func(name string) {
+		err := os.WriteFile(filepath.Join(WORKING_DIR, "build", name), []byte("marked"), 0644)
+		testerr(err, t)
+	}
+	for _, name := range []string{"a.html", "b.html", "image.png"} {
+		mark(name)
+	}
+
+	// changing only the body of a page (but not its summary or word count) only rebuilds that page
+	err := os.WriteFile(filepath.Join(SOURCE_DIR, "a.md"), []byte(dated+"# A\nfirst\n\nchanged paragraph"), 0644)
+	testerr(err, t)
+	testerr(commandBuild(nil), t)
+	if out := readBuilt(t, "a.html"); out != "A;B;<h1>A</h1>\n<p>first</p>\n<p>changed paragraph</p>\n" {
+		t.Errorf("changed page not rebuilt: %q", out)
+	}
+	if readBuilt(t, "b.html") != "marked" || readBuilt(t, "image.png") != "marked" {
+		t.Error("unchanged files were rebuilt")
+	}
+
+	// changing a title affects {{.Site}}, so every page is embedded in its template again
+	err = os.WriteFile(filepath.Join(SOURCE_DIR, "a.md"), []byte(dated+"# New A\nchanged"), 0644)
+	testerr(err, t)
+	testerr(commandBuild(nil), t)
+	if out := readBuilt(t, "b.html"); out != "New A;B;<h1>B</h1>\n<p>second</p>\n" {
+		t.Errorf("page not rebuilt after site change: %q", out)
+	}
+	if readBuilt(t, "image.png") != "marked" {
+		t.Error("unchanged asset was copied again")
+	}
+
+	// deleted outputs are always rebuilt
+	err = os.Remove(filepath.Join(WORKING_DIR, "build", "b.html"))
+	testerr(err, t)
+	testerr(commandBuild(nil), t)
+	if out := readBuilt(t, "b.html"); out != "New A;B;<h1>B</h1>\n<p>second</p>\n" {
+		t.Errorf("deleted output not rebuilt: %q", out)
+	}
+
+	// --force bypasses the cache
+	testerr(commandBuild([]string{"--force"}), t)
+	if readBuilt(t, "image.png") != "pixels" {
+		t.Error("--force did not rebuild everything")
+	}
+}
+
+func TestBuildCacheListingOrder(t *testing.T) {
+	setupWorkspace(t, "{{with .Paginator}}{{range .Items}}{{.Title}};{{end}}{{end}}", map[string]string{
+		"blog/index.md": "---\npaginate: true\n---\n",
+		"blog/a.md":     "# A\nfirst\n\nsecond paragraph",
+		"blog/b.md":     "# B\nsecond",
+	})
+	// undated pages are listed by the mtime of their files, newest first
+	touch := func(name string, mtime time.Time) {
+		testerr(os.Chtimes(filepath.Join(SOURCE_DIR, name), mtime, mtime), t)
+	}
+	touch("blog/a.md", time.Now().Add(-2*time.Hour))
+	touch("blog/b.md", time.Now().Add(-1*time.Hour))
+	testerr(commandBuild(nil), t)
+	if out := readBuilt(t, "blog/index.html"); out != "B;A;" {
+		t.Errorf("expected B;A;, got %q", out)
+	}
+
+	// editing a page moves it to the top, so the cached listing must not be kept
+	err := os.WriteFile(filepath.Join(SOURCE_DIR, "blog", "a.md"), []byte("# A\nfirst\n\nchanged paragraph"), 0644)
+	testerr(err, t)
+	touch("blog/a.md", time.Now())
+	testerr(commandBuild(nil), t)
+	incremental := readBuilt(t, "blog/index.html")
+	testerr(commandBuild([]string{"--force"}), t)
+	if full := readBuilt(t, "blog/index.html"); incremental != full || full != "A;B;" {
+		t.Errorf("incremental build wrote %q, full build wrote %q", incremental, full)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+// IMPORTS
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+//// LINK CHECKER
+// the link checker scans the html files in the build directory, and reports links to files that don't exist,
+// as well as links to '#fragments' that don't match the id of any element on the linked page.
+// External links are not checked. It runs with 'silvera check', or after every build if 'check_links' is set.
+// -----------------------------------------------------------------------------------
+
+// the elements that reference other files, and the attribute holding the reference.
+// only links of <a> and <area> elements may point to anchors, the other files just have to exist.
+var checkedAttributes = map[string]string{
+	"a":      "href",
+	"area":   "href",
+	"link":   "href",
+	"img":    "src",
+	"script": "src",
+	"source": "src",
+	"video":  "src",
+	"audio":  "src",
+	"iframe": "src",
+}
+
+// this struct holds what the link checker needs to know about a single html file.
+type checkedFile struct {
+	ids   map[string]bool // the ids (and names of <a> elements) that can be used as anchors
+	links []checkedLink   // the references to other files, in the order they appear
+}
+
+type checkedLink struct {
+	value    string // the value of the attribute, like "../post.html#intro"
+	isAnchor bool   // whether the fragment of the link has to match an element
+}
+
+// reads an html file, collecting its anchors and links.
+func readCheckedFile(path string) (*checkedFile, error) {
+	html_bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(html_bytes)))
+	if err != nil {
+		return nil, err
+	}
+	file := &checkedFile{ids: make(map[string]bool)}
+	doc.Find("[id], a[name]").Each(func(i int, element *goquery.Selection) {
+		if id, ok := element.Attr("id"); ok {
+			file.ids[id] = true
+		}
+		if name, ok := element.Attr("name"); ok && goquery.NodeName(element) == "a" {
+			file.ids[name] = true
+		}
+	})
+	// the selectors are combined, so the links are found in document order
+	var selectors []string
+	for name, attr := range checkedAttributes {
+		selectors = append(selectors, name+"["+attr+"]")
+	}
+	doc.Find(strings.Join(selectors, ", ")).Each(func(i int, element *goquery.Selection) {
+		name := goquery.NodeName(element)
+		link := checkedLink{value: element.AttrOr(checkedAttributes[name], ""), isAnchor: name == "a" || name == "area"}
+		file.links = append(file.links, link)
+	})
+	return file, nil
+}
+
+// returns the line of the source file a link was most likely written on, or 0 if it can't be found.
+// the link is searched in its built form first, and then in the forms it could have had in the Markdown source.
+func findSourceLine(src_path string, link string) int {
+	src_bytes, err := ioutil.ReadFile(src_path)
+	if err != nil {
+		return 0
+	}
+	link_path := strings.SplitN(strings.SplitN(link, "#", 2)[0], "?", 2)[0]
+	candidates := []string{link}
+	if strings.HasSuffix(link_path, ".html") || (strings.HasSuffix(link_path, "/") && len(link_path) > 1) {
+		name := strings.TrimSuffix(strings.TrimSuffix(link_path, ".html"), "/")
+		candidates = append(candidates, name+".md"+link[len(link_path):], name+".md", "[["+filepath.Base(name))
+	}
+	if link_path != "" {
+		candidates = append(candidates, link_path)
+	}
+	lines := strings.Split(string(src_bytes), "\n")
+	for _, candidate := range candidates {
+		for i, line := range lines {
+			if strings.Contains(line, candidate) {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// checks all html files below outdir, and reports every problem found.
+// sources maps the built files to the files they were built from, so problems can be reported for those.
+// returns the number of links that were checked.
+func checkLinks(outdir string, sources map[string]string, report *errorReport) int {
+	files := make(map[string]*checkedFile) // the parsed html files, by their path
+	var paths []string
+	filepath.Walk(outdir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			report.add(path, err)
+			return nil
+		}
+		if !info.IsDir() && strings.HasSuffix(path, ".html") {
+			file, err := readCheckedFile(path)
+			if err != nil {
+				report.add(path, err)
+				return nil
+			}
+			files[filepath.Clean(path)] = file
+			paths = append(paths, filepath.Clean(path))
+		}
+		return nil
+	})
+	sort.Strings(paths)
+
+	checked := 0
+	for _, path := range paths {
+		for _, link := range files[path].links {
+			problem := checkLink(outdir, path, link, files)
+			checked++
+			if problem == "" {
+				continue
+			}
+			// report the problem for the source file if possible, as that is where it has to be fixed
+			if src_path, ok := sources[path]; ok {
+				report.addLine(src_path, findSourceLine(src_path, link.value), fmt.Errorf("%s", problem))
+			} else {
+				report.add(path, fmt.Errorf("%s", problem))
+			}
+		}
+	}
+	return checked
+}
+
+// checks a single link found in the file at path. Returns a description of the problem, or "" if there is none.
+func checkLink(outdir string, path string, link checkedLink, files map[string]*checkedFile) string {
+	parsed, err := url.Parse(link.value)
+	if err != nil {
+		return fmt.Sprintf("invalid link %q: %v", link.value, err)
+	}
+	if parsed.Scheme != "" || parsed.Host != "" {
+		return "" // external links, and links like 'mailto:' are not checked
+	}
+
+	// find the file the link points to. A leading slash refers to the root of the build directory.
+	target := path
+	if parsed.Path != "" {
+		if strings.HasPrefix(parsed.Path, "/") {
+			target = filepath.Join(outdir, filepath.FromSlash(parsed.Path))
+		} else {
+			target = filepath.Join(filepath.Dir(path), filepath.FromSlash(parsed.Path))
+		}
+		info, err := os.Stat(target)
+		if err == nil && info.IsDir() {
+			target = filepath.Join(target, "index.html")
+			_, err = os.Stat(target)
+		}
+		if err != nil {
+			if link.isAnchor {
+				return fmt.Sprintf("broken link %q: %s does not exist", link.value, relativeToWorkspace(target))
+			}
+			return fmt.Sprintf("missing file %q: %s does not exist", link.value, relativeToWorkspace(target))
+		}
+	}
+
+	// '#' and '#top' always scroll to the top of the page, any other fragment needs a matching element
+	if !link.isAnchor || parsed.Fragment == "" || parsed.Fragment == "top" {
+		return ""
+	}
+	if file, ok := files[filepath.Clean(target)]; ok && !file.ids[parsed.Fragment] {
+		return fmt.Sprintf("broken anchor %q: no element with id %q", link.value, parsed.Fragment)
+	}
+	return ""
+}
+
+// checks the links of the whole build directory, mapping the built files back to their sources.
+func checkSiteLinks(config Config, jobs []buildJob, report *errorReport) {
+	sources := make(map[string]string)
+	for _, job := range jobs {
+		if job.isDir {
+			continue
+		}
+		outpath := job.outpath
+		if strings.HasSuffix(job.relpath, ".md") {
+			outpath = pageOutPath(outpath, job.conf)
+		}
+		sources[filepath.Clean(outpath)] = job.srcPath
+	}
+	checked := checkLinks(config.Outdir, sources, report)
+	fmt.Printf("check: %d links checked in %s\n", checked, config.Outdir)
+}
+
+// the 'check' command checks the links in the build directory, without building the site first.
+// it exits with a non-zero exit code if any link is broken, so it can be used in CI pipelines.
+func commandCheck(args []string) error {
+	var opts BuildOptions
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	addPublishFlags(flags, &opts)
+	flags.Parse(args)
+
+	config, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	if err != nil {
+		return err
+	}
+	report := &errorReport{}
+	// pages that are left out of the build have no output to check, use the same options as for the build
+	jobs, _ := filterUnpublished(collectJobs(config, report), opts)
+	checkSiteLinks(config, jobs, report)
+	return report.err()
+}
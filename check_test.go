@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckLinks(t *testing.T) {
+	setupWorkspace(t, "<html><body>{{.Body}}<a href=\"/style.css\">s</a></body></html>", map[string]string{
+		"index.md":      "# Home\n[ok](sub/other.md#section)\n[bad anchor](sub/other.md#nope)\n![img](missing.png)\n[gone](gone.html)\n[ext](https://example.org/x)\n[top](#top)",
+		"sub/other.md":  "# Other\n## Section\n[home](../index.md) [dir](/sub/) [self](#other)",
+		"sub/index.md":  "# Sub",
+		"style.css":     "body {}",
+		"sub/image.png": "not really an image",
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	conf.ParserOptions.WithAutoHeadingID = true
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+
+	err = commandCheck(nil)
+	var errs BuildErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected build errors, got %v", err)
+	}
+	expected := []string{
+		`src/index.md:3: broken anchor "sub/other.html#nope": no element with id "nope"`,
+		`src/index.md:4: missing file "missing.png": build/missing.png does not exist`,
+		`src/index.md:5: broken link "gone.html": build/gone.html does not exist`,
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), errs)
+	}
+	for i, e := range errs {
+		if e.Error() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], e.Error())
+		}
+	}
+
+	// with check_links, the build itself fails
+	conf.CheckLinks = true
+	writeConfig(t, conf)
+	if err := commandBuild(nil); !errors.As(err, &errs) || len(errs) != len(expected) {
+		t.Errorf("expected the build to fail with %d errors, got %v", len(expected), err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+// IMPORTS
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+//// DATA FILES
+// structured data can be put into the 'data' directory of the workspace, as YAML, JSON, TOML or CSV files.
+// Every template can access it through {{.Data}}, named after the files: 'data/team.yaml' becomes {{.Data.team}},
+// and 'data/releases/v1.json' becomes {{.Data.releases.v1}}.
+// CSV files become a list of rows, where each row maps the column names from the first line to its values.
+// -----------------------------------------------------------------------------------
+
+// the position of yaml errors is only part of their message, like "yaml: line 3: did not find expected key"
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// toml errors come with their position, which is repeated at the beginning of their message
+var tomlErrorPrefix = regexp.MustCompile(`^toml: line \d+( \(last key "[^"]*"\))?: `)
+
+// returns the line and column of the byte at offset in data. Both start at 1.
+func lineAndColumn(data []byte, offset int) (int, int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// parses a single data file, depending on its extension. Parse errors are returned as a BuildError,
+// holding the position of the error if it is known.
+func parseDataFile(path string, data []byte) (interface{}, error) {
+	var value interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+				line, _ := strconv.Atoi(match[1])
+				return nil, &BuildError{Path: path, Line: line, Err: errors.New(match[2])}
+			}
+			return nil, &BuildError{Path: path, Err: err}
+		}
+		return normalizeYamlValue(value), nil
+
+	case ".json":
+		if err := json.Unmarshal(data, &value); err != nil {
+			var syntax_err *json.SyntaxError
+			if errors.As(err, &syntax_err) {
+				line, column := lineAndColumn(data, int(syntax_err.Offset)-1) // the offset is right after the offending byte
+				return nil, &BuildError{Path: path, Line: line, Column: column, Err: err}
+			}
+			return nil, &BuildError{Path: path, Err: err}
+		}
+		return value, nil
+
+	case ".toml":
+		table := make(map[string]interface{})
+		if _, err := toml.Decode(string(data), &table); err != nil {
+			var parse_err toml.ParseError
+			if errors.As(err, &parse_err) {
+				line, column := lineAndColumn(data, parse_err.Position.Start)
+				message := tomlErrorPrefix.ReplaceAllString(parse_err.Error(), "")
+				return nil, &BuildError{Path: path, Line: line, Column: column, Err: errors.New(message)}
+			}
+			return nil, &BuildError{Path: path, Err: err}
+		}
+		return table, nil
+
+	case ".csv":
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			var parse_err *csv.ParseError
+			if errors.As(err, &parse_err) {
+				return nil, &BuildError{Path: path, Line: parse_err.Line, Column: parse_err.Column, Err: parse_err.Err}
+			}
+			return nil, &BuildError{Path: path, Err: err}
+		}
+		rows := []interface{}{}
+		if len(records) > 0 {
+			header := records[0]
+			for _, record := range records[1:] {
+				row := make(map[string]interface{})
+				for i, name := range header {
+					row[name] = record[i]
+				}
+				rows = append(rows, row)
+			}
+		}
+		return rows, nil
+	}
+	return nil, nil // other files are ignored
+}
+
+// loads all data files below the given directory. A missing directory simply means there is no data.
+// the first file that can't be parsed stops the loading, as every page might depend on it.
+func loadData(data_dir string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	err := filepath.Walk(data_dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == data_dir {
+				return nil
+			}
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") || info.IsDir() {
+			return nil
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return &BuildError{Path: path, Err: err}
+		}
+		value, err := parseDataFile(path, contents)
+		if err != nil || value == nil {
+			return err
+		}
+
+		// find the map the value belongs into, creating maps for the directories on the way
+		rel, _ := filepath.Rel(data_dir, path)
+		keys := strings.Split(filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel))), "/")
+		parent := data
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := parent[key].(map[string]interface{})
+			if !ok {
+				if _, exists := parent[key]; exists {
+					return &BuildError{Path: path, Err: fmt.Errorf("%q is also defined by a file", key)}
+				}
+				child = make(map[string]interface{})
+				parent[key] = child
+			}
+			parent = child
+		}
+		name := keys[len(keys)-1]
+		if _, exists := parent[name]; exists {
+			return &BuildError{Path: path, Err: fmt.Errorf("%q is defined by more than one file or directory", name)}
+		}
+		parent[name] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDataFiles(t *testing.T) {
+	setupWorkspace(t, `{{range .Data.team}}{{.name}} ({{.role}});{{end}}|{{.Data.site.title}}|{{index .Data.releases.v1.features 1}}|`+
+		`{{range .Data.links}}<a href="{{.url}}">{{.label}}</a>{{end}}|{{.Data.nested.deep.value}}`, map[string]string{
+		"index.md": "# Home",
+	})
+	files := map[string]string{
+		"team.yaml":           "- name: Ada\n  role: lead\n- name: Bob\n  role: dev\n",
+		"site.toml":           "title = \"My Site\"\n",
+		"releases/v1.json":    `{"features": ["fast", "small"]}`,
+		"links.csv":           "label,url\nHome,/index.html\nDocs,/docs/\n",
+		"nested/deep.yml":     "value: 42\n",
+		"nested/.hidden.yaml": "not: [valid",
+		"nested/notes.txt":    "ignored",
+	}
+	for name, contents := range files {
+		path := filepath.Join(DATA_DIR, filepath.FromSlash(name))
+		testerr(os.MkdirAll(filepath.Dir(path), 0755), t)
+		testerr(os.WriteFile(path, []byte(contents), 0644), t)
+	}
+	testerr(commandBuild(nil), t)
+	expected := `Ada (lead);Bob (dev);|My Site|small|<a href="/index.html">Home</a><a href="/docs/">Docs</a>|42`
+	if out := readBuilt(t, "index.html"); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	// malformed files fail the build with their path and position
+	broken := map[string]string{
+		"broken.json": "{\n  \"a\": 1,\n  \"b\": }\n",
+		"broken.toml": "a = 1\nb = = 2\n",
+		"broken.yaml": "a: 1\nb: [2\n",
+		"broken.csv":  "a,b\n1,\"2\n3,4\n",
+	}
+	positions := map[string]string{
+		"broken.json": "data/broken.json:3:8: ",
+		"broken.toml": "data/broken.toml:2:5: expected value but found",
+		"broken.yaml": "data/broken.yaml:2: did not find expected ',' or ']'",
+		"broken.csv":  "data/broken.csv:3:5: ",
+	}
+	for name, contents := range broken {
+		path := filepath.Join(DATA_DIR, name)
+		testerr(os.WriteFile(path, []byte(contents), 0644), t)
+		var build_err *BuildError
+		err := commandBuild(nil)
+		if !errors.As(err, &build_err) || !strings.HasPrefix(build_err.Error(), positions[name]) {
+			t.Errorf("%s: expected an error starting with %q, got %v", name, positions[name], err)
+		}
+		testerr(os.Remove(path), t)
+	}
+}
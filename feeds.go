@@ -0,0 +1,337 @@
+package main
+
+// IMPORTS
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//// FEEDS
+// the following functions generate RSS, Atom and JSON feeds for the pages of a directory,
+// as configured in the 'feeds' section of the global 'silvera.conf'.
+// Each feed lists the pages newest first, using their front matter dates.
+// -----------------------------------------------------------------------------------
+
+// the file names of the generated feeds, by format
+var FEED_FILES = map[string]string{
+	"rss":  "feed.xml",
+	"atom": "atom.xml",
+	"json": "feed.json",
+}
+
+// the following structs describe an RSS 2.0 feed, as written by encoding/xml.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate,omitempty"`
+	Author      string `xml:"author,omitempty"`
+	Description string `xml:"description"`
+}
+
+// the following structs describe an Atom feed, as written by encoding/xml.
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   *atomAuthor `xml:"author,omitempty"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Summary *atomText   `xml:"summary,omitempty"`
+	Content atomText    `xml:"content"`
+}
+
+// the following structs describe a JSON Feed (version 1.1), as written by encoding/json.
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Authors     []jsonAuthor   `json:"authors,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url"`
+	Title         string       `json:"title"`
+	ContentHTML   string       `json:"content_html"`
+	Summary       string       `json:"summary,omitempty"`
+	DatePublished string       `json:"date_published,omitempty"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+}
+
+// joins the base_url from the config with a site relative url like "/blog/post.html".
+// without a base_url, the relative url is returned unchanged.
+func absoluteURL(base_url string, url string) string {
+	if base_url == "" {
+		return url
+	}
+	return strings.TrimSuffix(base_url, "/") + "/" + strings.TrimPrefix(url, "/")
+}
+
+// turns a directory relative to 'src' or the 'outdir' (like "blog") into a site relative url (like "/blog/").
+func dirURL(dir string) string {
+	trimmed := strings.Trim(filepath.ToSlash(dir), "/")
+	if trimmed == "" || trimmed == "." {
+		return "/"
+	}
+	return "/" + trimmed + "/"
+}
+
+// returns the source directory of a feed, relative to 'src'. The directory may also be given
+// including the source directory itself, like "src/blog", which is the same as "blog".
+func feedSource(source string) string {
+	source = strings.Trim(filepath.ToSlash(source), "/")
+	src_name := filepath.Base(SOURCE_DIR)
+	if source == src_name || strings.HasPrefix(source, src_name+"/") {
+		source = strings.TrimPrefix(strings.TrimPrefix(source, src_name), "/")
+	}
+	return source
+}
+
+// returns the summary of a page as plain text, as feeds don't allow html in summaries.
+func feedSummary(page *Page) string {
+	return plainText(string(page.Summary))
+}
+
+// returns the html of a page as it is embedded in feeds. Feed readers show it away from the site,
+// so its relative links and images are made absolute using the base url.
+func feedContent(page *Page, base_url string) string {
+	return string(rewriteRelativeLinks(page.body, page.URL, func(site_url string, ref string) string {
+		return absoluteURL(base_url, site_url)
+	}))
+}
+
+// returns the author of a page, as given by its front matter, or the default author of the feed.
+func feedAuthor(page *Page, feed FeedConf) string {
+	if author, ok := page.Params["author"].(string); ok && author != "" {
+		return author
+	}
+	return feed.Author
+}
+
+// returns the pages below the source directory of the feed, newest first.
+// indexes and listing pages (see pagination.go) present the other pages, so they are no entries of their own.
+func feedPages(feed FeedConf, site *Site) []*Page {
+	var pages []*Page
+	for _, page := range pagesBelow(feed.Source, site) {
+		_, listing := page.Params["paginate"]
+		if filepath.Base(page.relpath) != "index.md" && !listing {
+			pages = append(pages, page)
+		}
+	}
+	if feed.Limit > 0 && len(pages) > feed.Limit {
+		pages = pages[:feed.Limit]
+	}
+	return pages
+}
+
+// builds an RSS 2.0 feed from the given pages.
+func buildRssFeed(feed FeedConf, pages []*Page, base_url string, home_url string) ([]byte, error) {
+	channel := rssChannel{
+		Title:       feed.Title,
+		Link:        home_url,
+		Description: feed.Description,
+	}
+	if len(pages) > 0 {
+		channel.LastBuildDate = pages[0].lastModified().Format(time.RFC1123Z)
+	}
+	for _, page := range pages {
+		url := absoluteURL(base_url, page.URL)
+		channel.Items = append(channel.Items, rssItem{
+			Title:       page.Title,
+			Link:        url,
+			GUID:        url,
+			PubDate:     page.lastModified().Format(time.RFC1123Z),
+			Author:      feedAuthor(page, feed),
+			Description: feedContent(page, base_url),
+		})
+	}
+
+	out, err := xml.MarshalIndent(rssFeed{Version: "2.0", Channel: channel}, "", "  ")
+	return append([]byte(xml.Header), out...), err
+}
+
+// builds an Atom feed from the given pages.
+func buildAtomFeed(feed FeedConf, pages []*Page, base_url string, home_url string, feed_url string) ([]byte, error) {
+	atom := atomFeed{
+		Title:    feed.Title,
+		Subtitle: feed.Description,
+		ID:       feed_url,
+		Links:    []atomLink{{Href: home_url}, {Href: feed_url, Rel: "self"}},
+	}
+	if feed.Author != "" {
+		atom.Author = &atomAuthor{Name: feed.Author}
+	}
+	if len(pages) > 0 {
+		atom.Updated = pages[0].lastModified().Format(time.RFC3339)
+	} else {
+		atom.Updated = time.Now().Format(time.RFC3339)
+	}
+	for _, page := range pages {
+		url := absoluteURL(base_url, page.URL)
+		entry := atomEntry{
+			Title:   page.Title,
+			ID:      url,
+			Updated: page.lastModified().Format(time.RFC3339),
+			Link:    atomLink{Href: url},
+			Content: atomText{Type: "html", Body: feedContent(page, base_url)},
+		}
+		if author := feedAuthor(page, feed); author != "" && author != feed.Author {
+			entry.Author = &atomAuthor{Name: author}
+		}
+		if summary := feedSummary(page); summary != "" {
+			entry.Summary = &atomText{Type: "text", Body: summary}
+		}
+		atom.Entries = append(atom.Entries, entry)
+	}
+
+	out, err := xml.MarshalIndent(atom, "", "  ")
+	return append([]byte(xml.Header), out...), err
+}
+
+// builds a JSON Feed from the given pages.
+func buildJsonFeed(feed FeedConf, pages []*Page, base_url string, home_url string, feed_url string) ([]byte, error) {
+	json_feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feed.Title,
+		HomePageURL: home_url,
+		FeedURL:     feed_url,
+		Description: feed.Description,
+		Items:       []jsonFeedItem{},
+	}
+	if feed.Author != "" {
+		json_feed.Authors = []jsonAuthor{{Name: feed.Author}}
+	}
+	for _, page := range pages {
+		url := absoluteURL(base_url, page.URL)
+		item := jsonFeedItem{
+			ID:            url,
+			URL:           url,
+			Title:         page.Title,
+			ContentHTML:   feedContent(page, base_url),
+			Summary:       feedSummary(page),
+			DatePublished: page.lastModified().Format(time.RFC3339),
+		}
+		if author := feedAuthor(page, feed); author != "" && author != feed.Author {
+			item.Authors = []jsonAuthor{{Name: author}}
+		}
+		json_feed.Items = append(json_feed.Items, item)
+	}
+	return json.MarshalIndent(json_feed, "", "  ")
+}
+
+// writes all the feeds configured in the given config to its outdir.
+// errors are added to the report, with the config file as their path.
+func writeFeeds(config Config, site *Site, report *errorReport) {
+	conf_path := filepath.Join(WORKING_DIR, "silvera.conf")
+	if len(config.Feeds) > 0 && config.BaseURL == "" {
+		fmt.Println("Warning: no base_url is set in silvera.conf, the feeds will contain relative links.")
+	}
+
+	for _, feed := range config.Feeds {
+		feed.Source = feedSource(feed.Source)
+		if info, err := os.Stat(filepath.Join(SOURCE_DIR, filepath.FromSlash(feed.Source))); err != nil || !info.IsDir() {
+			report.add(conf_path, fmt.Errorf("feed for %s: no such directory in src", feed.Source))
+			continue
+		}
+		output := feed.Output
+		if output == "" {
+			output = feed.Source
+		}
+		out_dir := filepath.Join(config.Outdir, filepath.FromSlash(output))
+		if err := os.MkdirAll(out_dir, 0755); err != nil {
+			report.add(out_dir, err)
+			continue
+		}
+
+		formats := feed.Formats
+		if len(formats) == 0 {
+			formats = []string{"rss", "atom", "json"}
+		}
+
+		pages := feedPages(feed, site)
+		if len(pages) == 0 {
+			report.warn(conf_path, fmt.Sprintf("feed for %s: the directory contains no pages", feed.Source))
+		}
+		url_dir := dirURL(output)
+		home_url := absoluteURL(config.BaseURL, url_dir)
+		for _, format := range formats {
+			file_name, ok := FEED_FILES[format]
+			if !ok {
+				report.add(conf_path, fmt.Errorf("unknown feed format %q", format))
+				continue
+			}
+			feed_url := absoluteURL(config.BaseURL, url_dir+file_name)
+
+			var out []byte
+			var err error
+			switch format {
+			case "rss":
+				out, err = buildRssFeed(feed, pages, config.BaseURL, home_url)
+			case "atom":
+				out, err = buildAtomFeed(feed, pages, config.BaseURL, home_url, feed_url)
+			case "json":
+				out, err = buildJsonFeed(feed, pages, config.BaseURL, home_url, feed_url)
+			}
+			if err == nil {
+				err = ioutil.WriteFile(filepath.Join(out_dir, file_name), out, 0644)
+			}
+			if err != nil {
+				report.add(conf_path, fmt.Errorf("feed for %s: %w", feed.Source, err))
+				continue
+			}
+			fmt.Println("feed:", feed.Source, "->", filepath.Join(out_dir, file_name))
+		}
+	}
+}
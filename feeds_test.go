@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFeeds(t *testing.T) {
+	setupWorkspace(t, "{{.Body}}", map[string]string{
+		"index.md":           "# Home",
+		"blog/index.md":      "---\ndate: 2023-01-01\n---\n# Blog",
+		"blog/archive.md":    "---\ndate: 2023-01-01\npaginate: blog\n---\n# Archive",
+		"blog/first.md":      "---\ndate: 2022-01-01\ndescription: The first post.\n---\n# First",
+		"blog/second.md":     "---\ndate: 2022-02-01\nauthor: Someone Else\n---\n# Second\nText.\n\n[First](first.md) ![](pic.png) [Home](../index.md#top)",
+		"blog/nested/old.md": "---\ndate: 2021-01-01\n---\n# Old",
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	conf.BaseURL = "https://example.org/"
+	conf.Feeds = []FeedConf{{Source: "blog", Title: "Blog", Author: "Me", Limit: 2}}
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+
+	// RSS
+	var rss rssFeed
+	err = xml.Unmarshal([]byte(readBuilt(t, "blog/feed.xml")), &rss)
+	testerr(err, t)
+	items := rss.Channel.Items
+	if len(items) != 2 || items[0].Title != "Second" || items[1].Title != "First" {
+		t.Fatalf("unexpected rss items: %+v", items)
+	}
+	if items[0].Link != "https://example.org/blog/second.html" || items[0].Author != "Someone Else" || items[1].Author != "Me" {
+		t.Errorf("unexpected rss item: %+v", items[0])
+	}
+	// feed readers show the body away from the site, so its links are absolute
+	body := "<h1>Second</h1>\n<p>Text.</p>\n" + `<p><a href="https://example.org/blog/first.html">First</a> <img src="https://example.org/blog/pic.png" alt=""/> <a href="https://example.org/index.html#top">Home</a></p>` + "\n"
+	if items[0].Description != body {
+		t.Errorf("unexpected rss body: %q", items[0].Description)
+	}
+
+	// Atom
+	var atom atomFeed
+	err = xml.Unmarshal([]byte(readBuilt(t, "blog/atom.xml")), &atom)
+	testerr(err, t)
+	if len(atom.Entries) != 2 || atom.ID != "https://example.org/blog/atom.xml" || atom.Updated != "2022-02-01T00:00:00Z" {
+		t.Fatalf("unexpected atom feed: %+v", atom)
+	}
+	if atom.Entries[0].Content.Body != body {
+		t.Errorf("unexpected atom body: %q", atom.Entries[0].Content.Body)
+	}
+	if atom.Entries[1].Summary == nil || atom.Entries[1].Summary.Body != "The first post." {
+		t.Errorf("unexpected atom summary: %+v", atom.Entries[1])
+	}
+
+	// JSON Feed
+	var json_feed jsonFeed
+	err = json.Unmarshal([]byte(readBuilt(t, "blog/feed.json")), &json_feed)
+	testerr(err, t)
+	if len(json_feed.Items) != 2 || json_feed.Items[0].URL != "https://example.org/blog/second.html" || json_feed.Items[0].DatePublished != "2022-02-01T00:00:00Z" {
+		t.Errorf("unexpected json feed: %+v", json_feed)
+	}
+	if len(json_feed.Items) > 0 && json_feed.Items[0].ContentHTML != body {
+		t.Errorf("unexpected json body: %q", json_feed.Items[0].ContentHTML)
+	}
+	// without a summary in the front matter, the first paragraph is used
+	if len(json_feed.Items) > 0 && json_feed.Items[0].Summary != "Text." {
+		t.Errorf("unexpected json summary: %q", json_feed.Items[0].Summary)
+	}
+
+	// a feed for the whole site, only in a single format. Indexes and listings are left out here as well
+	conf.Feeds = []FeedConf{{Title: "Everything", Formats: []string{"json"}}}
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+	err = json.Unmarshal([]byte(readBuilt(t, "feed.json")), &json_feed)
+	testerr(err, t)
+	if len(json_feed.Items) != 3 || json_feed.FeedURL != "https://example.org/feed.json" {
+		t.Errorf("unexpected site feed: %+v", json_feed)
+	}
+	if _, err := os.Stat(filepath.Join(WORKING_DIR, "build", "feed.xml")); err == nil {
+		t.Error("feed written in a format that was not configured")
+	}
+}
+
+func TestFeedSources(t *testing.T) {
+	setupWorkspace(t, "{{.Body}}", map[string]string{
+		"blog/post.md":  "# Post",
+		"empty/pic.png": "pixels",
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+
+	// the source directory may be given including 'src'
+	conf.Feeds = []FeedConf{{Source: "src/blog", Title: "Blog", Formats: []string{"json"}}}
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+	var json_feed jsonFeed
+	testerr(json.Unmarshal([]byte(readBuilt(t, "blog/feed.json")), &json_feed), t)
+	if len(json_feed.Items) != 1 || json_feed.Items[0].Title != "Post" {
+		t.Errorf("unexpected feed: %+v", json_feed)
+	}
+	if _, err := os.Stat(filepath.Join(WORKING_DIR, "build", "src")); err == nil {
+		t.Error("feed written below build/src")
+	}
+
+	// a directory that doesn't exist fails the build
+	conf.Feeds = []FeedConf{{Source: "blgo", Title: "Blog"}}
+	writeConfig(t, conf)
+	if err := commandBuild(nil); err == nil || !strings.Contains(err.Error(), "feed for blgo: no such directory in src") {
+		t.Errorf("expected an error for a missing source directory, got %v", err)
+	}
+
+	// a directory without pages only gets a warning, the feed is written anyway
+	conf.Feeds = []FeedConf{{Source: "empty", Title: "Empty", Formats: []string{"json"}}}
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+	readBuilt(t, "empty/feed.json")
+	report := &errorReport{}
+	conf.Outdir = filepath.Join(WORKING_DIR, "build")
+	writeFeeds(conf, &Site{}, report)
+	if len(report.errors) != 0 || len(report.warnings) != 1 || !strings.Contains(report.warnings[0].Error(), "contains no pages") {
+		t.Errorf("expected a warning for the empty feed, got %v and %v", report.errors, report.warnings)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+// IMPORTS
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	gm "github.com/yuin/goldmark"
+)
+
+//// TEMPLATE FUNCTIONS
+// the following functions can be used in all templates, in addition to golangs builtin ones.
+// Where it makes sense, the value a function works on is its last argument, so it can be piped into it,
+// like {{.Title | truncate 20}} or {{.Date | dateFormat "Jan 2, 2006"}}.
+// -----------------------------------------------------------------------------------
+
+// returns the functions available to the templates. Some of them depend on the global config.
+func templateFuncs(config Config) template.FuncMap {
+	return template.FuncMap{
+		// dates
+		"dateFormat": dateFormat,
+		"now":        time.Now,
+
+		// urls
+		"relURL": func(path string) string { return relURL(config.BaseURL, path) },
+		"absURL": func(path string) string { return absURL(config.BaseURL, path) },
+
+		// text
+		"slugify":     slugify,
+		"truncate":    truncate,
+		"markdownify": func(md string) (template.HTML, error) { return markdownify(md, config) },
+		"safeHTML":    func(s string) template.HTML { return template.HTML(s) },
+		"jsonify":     jsonify,
+		"readFile":    readFile,
+
+		// page collections
+		"where":  where,
+		"sortBy": sortBy,
+
+		// strings
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"title":      titleCase,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix string, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix string, s string) string { return strings.TrimSuffix(s, suffix) },
+		"hasPrefix":  func(prefix string, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix string, s string) bool { return strings.HasSuffix(s, suffix) },
+		"contains":   func(substr string, s string) bool { return strings.Contains(s, substr) },
+		"replace":    func(old string, new string, s string) string { return strings.ReplaceAll(s, old, new) },
+		"split":      func(sep string, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+	}
+}
+
+// formats a date using a golang time layout, like "2006-01-02". The date may be a time or a front matter string.
+func dateFormat(layout string, date interface{}) string {
+	parsed := parseDate(date)
+	if parsed.IsZero() {
+		return ""
+	}
+	return parsed.Format(layout)
+}
+
+// returns the path relative to the root of the site, respecting the path of the base_url.
+// with a base_url of 'https://example.org/blog', "css/style.css" becomes "/blog/css/style.css".
+func relURL(base_url string, path string) string {
+	if parsed, err := url.Parse(path); err == nil && parsed.IsAbs() {
+		return path // already a full url
+	}
+	base_path := ""
+	if parsed, err := url.Parse(base_url); err == nil {
+		base_path = strings.TrimSuffix(parsed.Path, "/")
+	}
+	return base_path + "/" + strings.TrimPrefix(path, "/")
+}
+
+// returns the full url of the path, using the base_url, like "https://example.org/css/style.css".
+// without a base_url, this is the same as relURL.
+func absURL(base_url string, path string) string {
+	if parsed, err := url.Parse(path); err == nil && parsed.IsAbs() {
+		return path
+	}
+	if base_url == "" {
+		return relURL(base_url, path)
+	}
+	return absoluteURL(base_url, path)
+}
+
+// turns a text into a form that can be used in urls and ids, like "Hello, World!" -> "hello-world".
+func slugify(text string) string {
+	var b strings.Builder
+	dash := false // whether a dash is due before the next letter
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// shortens a text to at most length characters, cutting at a word boundary if possible, and adding an ellipsis.
+func truncate(length int, text string) string {
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	runes := []rune(text)
+	cut := string(runes[:length])
+	if idx := strings.LastIndexAny(cut, " \t\n"); idx > 0 {
+		cut = cut[:idx]
+	}
+	return strings.TrimRight(cut, " \t\n.,;:") + "…"
+}
+
+// renders a Markdown string to html, using the extensions and options of the global config.
+func markdownify(md string, config Config) (template.HTML, error) {
+	converter := gm.New(
+		gm.WithExtensions(buildExtensionList(config)...),
+		gm.WithParserOptions(buildParserOptList(config)...),
+		gm.WithRendererOptions(buildRendererOptList(config)...),
+	)
+	var buf bytes.Buffer
+	if err := converter.Convert([]byte(md), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// returns the value as json. It can be embedded into scripts as it is, like <script>var pages = {{jsonify .Site.Pages}};</script>.
+func jsonify(value interface{}) (template.JS, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return template.JS(data), nil
+}
+
+// returns the contents of a file. Relative paths are relative to the workspace.
+func readFile(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(WORKING_DIR, filepath.FromSlash(path))
+	}
+	contents, err := ioutil.ReadFile(path)
+	return string(contents), err
+}
+
+// upper-cases the first letter of every word.
+func titleCase(text string) string {
+	runes := []rune(text)
+	for i, r := range runes {
+		if i == 0 || unicode.IsSpace(runes[i-1]) {
+			runes[i] = unicode.ToUpper(r)
+		}
+	}
+	return string(runes)
+}
+
+// joins the elements of a list into a string. The list may hold any kind of values, a missing list results in "".
+func join(sep string, list interface{}) (string, error) {
+	if list == nil {
+		return "", nil
+	}
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: expected a list, got %T", list)
+	}
+	parts := make([]string, v.Len())
+	for i := range parts {
+		parts[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// returns the value of a page field, like "Title" or "Date". Front matter values are accessed as "Params.author".
+func pageField(page *Page, key string) (interface{}, error) {
+	if strings.HasPrefix(key, "Params.") {
+		return page.Params[strings.TrimPrefix(key, "Params.")], nil
+	}
+	switch key {
+	case "Path":
+		return page.Path, nil
+	case "URL":
+		return page.URL, nil
+	case "Title":
+		return page.Title, nil
+	case "Date":
+		return page.Date, nil
+	}
+	return nil, fmt.Errorf("unknown page field %q", key)
+}
+
+// compares two values, which may be numbers, strings or dates. Returns -1, 0 or 1, and whether they could be compared.
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	if a_num, ok := toFloat(a); ok {
+		if b_num, ok := toFloat(b); ok {
+			switch {
+			case a_num < b_num:
+				return -1, true
+			case a_num > b_num:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if a_time, ok := a.(time.Time); ok {
+		b_time := parseDate(b)
+		if b_time.IsZero() {
+			return 0, false
+		}
+		switch {
+		case a_time.Before(b_time):
+			return -1, true
+		case a_time.After(b_time):
+			return 1, true
+		}
+		return 0, true
+	}
+	a_str, a_ok := a.(string)
+	b_str, b_ok := b.(string)
+	if a_ok && b_ok {
+		return strings.Compare(a_str, b_str), true
+	}
+	return 0, false
+}
+
+// converts any kind of number to a float.
+func toFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// returns the pages whose field matches a value. It is called either like {{where .Site.Pages "Params.category" "news"}},
+// or with an operator, like {{where .Site.Pages "Date" ">" "2022-01-01"}}.
+// the operators are "==", "!=", "<", "<=", ">", ">=" and "in", which matches pages whose list field contains the value,
+// like {{where .Site.Pages "Params.tags" "in" "go"}}.
+func where(pages []*Page, key string, args ...interface{}) ([]*Page, error) {
+	var op string
+	var match interface{}
+	switch len(args) {
+	case 1:
+		op, match = "==", args[0]
+	case 2:
+		var ok bool
+		if op, ok = args[0].(string); !ok {
+			return nil, fmt.Errorf("where: the operator has to be a string, got %T", args[0])
+		}
+		match = args[1]
+	default:
+		return nil, fmt.Errorf("where: expected a value, or an operator and a value")
+	}
+
+	result := []*Page{}
+	for _, page := range pages {
+		value, err := pageField(page, key)
+		if err != nil {
+			return nil, err
+		}
+		var matches bool
+		switch op {
+		case "==", "=":
+			cmp, ok := compareValues(value, match)
+			matches = (ok && cmp == 0) || (!ok && reflect.DeepEqual(value, match))
+		case "!=":
+			cmp, ok := compareValues(value, match)
+			matches = !((ok && cmp == 0) || (!ok && reflect.DeepEqual(value, match)))
+		case "<", "<=", ">", ">=":
+			cmp, ok := compareValues(value, match)
+			matches = ok && ((op == "<" && cmp < 0) || (op == "<=" && cmp <= 0) || (op == ">" && cmp > 0) || (op == ">=" && cmp >= 0))
+		case "in":
+			list, _ := value.([]interface{})
+			matches = listContains(list, match)
+		default:
+			return nil, fmt.Errorf("where: unknown operator %q", op)
+		}
+		if matches {
+			result = append(result, page)
+		}
+	}
+	return result, nil
+}
+
+// returns the pages sorted by a field, like {{sortBy .Site.Pages "Title"}} or {{sortBy .Site.Pages "Date" "desc"}}.
+// pages whose values can't be compared keep their order.
+func sortBy(pages []*Page, key string, order ...string) ([]*Page, error) {
+	desc := len(order) > 0 && order[0] == "desc"
+	values := make(map[*Page]interface{})
+	for _, page := range pages {
+		value, err := pageField(page, key)
+		if err != nil {
+			return nil, err
+		}
+		values[page] = value
+	}
+	sorted := make([]*Page, len(pages))
+	copy(sorted, pages)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		cmp, _ := compareValues(values[sorted[i]], values[sorted[j]])
+		if desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+	return sorted, nil
+}
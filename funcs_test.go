@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	slugs := map[string]string{"Hello, World!": "hello-world", "  Go 1.18 -- Release ": "go-1-18-release", "Ünïcode": "ünïcode"}
+	for text, slug := range slugs {
+		if out := slugify(text); out != slug {
+			t.Errorf("slugify(%q): expected %q, got %q", text, slug, out)
+		}
+	}
+	if out := truncate(12, "The quick brown fox"); out != "The quick…" {
+		t.Errorf("unexpected truncation: %q", out)
+	}
+	if out := truncate(50, "short"); out != "short" {
+		t.Errorf("unexpected truncation: %q", out)
+	}
+	urls := [][4]string{ // base_url, path, relURL, absURL
+		{"", "css/style.css", "/css/style.css", "/css/style.css"},
+		{"https://example.org/blog/", "/css/style.css", "/blog/css/style.css", "https://example.org/blog/css/style.css"},
+		{"https://example.org", "https://other.org/x", "https://other.org/x", "https://other.org/x"},
+	}
+	for _, u := range urls {
+		if rel, abs := relURL(u[0], u[1]), absURL(u[0], u[1]); rel != u[2] || abs != u[3] {
+			t.Errorf("%q with base %q: expected %q and %q, got %q and %q", u[1], u[0], u[2], u[3], rel, abs)
+		}
+	}
+
+	old := &Page{Title: "Old", Date: parseDate("2020-01-01"), Params: map[string]interface{}{"tags": []interface{}{"go"}, "weight": 2}}
+	mid := &Page{Title: "Mid", Date: parseDate("2021-01-01"), Params: map[string]interface{}{"tags": []interface{}{"web"}, "weight": 1}}
+	recent := &Page{Title: "New", Date: parseDate("2022-01-01"), Params: map[string]interface{}{"tags": []interface{}{"go", "web"}, "weight": 3}}
+	pages := []*Page{old, mid, recent}
+	titles := func(pages []*Page, err error) string {
+		testerr(err, t)
+		var titles []string
+		for _, page := range pages {
+			titles = append(titles, page.Title)
+		}
+		return strings.Join(titles, ",")
+	}
+	checks := [][2]string{
+		{titles(where(pages, "Params.tags", "in", "go")), "Old,New"},
+		{titles(where(pages, "Date", ">=", "2021-01-01")), "Mid,New"},
+		{titles(where(pages, "Params.weight", 1)), "Mid"},
+		{titles(where(pages, "Title", "!=", "Mid")), "Old,New"},
+		{titles(sortBy(pages, "Params.weight")), "Mid,Old,New"},
+		{titles(sortBy(pages, "Date", "desc")), "New,Mid,Old"},
+		{titles(sortBy(pages, "Title")), "Mid,New,Old"},
+	}
+	for i, check := range checks {
+		if check[0] != check[1] {
+			t.Errorf("check %d: expected %q, got %q", i, check[1], check[0])
+		}
+	}
+	if _, err := where(pages, "Nope", "x"); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+
+	// all functions are available in templates
+	setupWorkspace(t, `{{.Params.date | dateFormat "Jan 2, 2006"}}|{{relURL "x.css"}}|{{absURL "x.css"}}|{{slugify .Title}}|`+
+		`{{markdownify "*hi*"}}|{{range sortBy (where .Site.Pages "Params.draft" "!=" true) "Title" "desc"}}{{.Title}};{{end}}|`+
+		`{{readFile "note.txt" | trim | upper}}|<script>var p = {{jsonify .Params.tags}};</script>|{{join ", " .Params.tags}}`, map[string]string{
+		"index.md": "---\ntitle: Hello World\ndate: 2022-06-01\ntags: [a, b]\n---\n# Hello",
+		"other.md": "# Other",
+		"draft.md": "---\ndraft: true\n---\n# Draft",
+	})
+	testerr(os.WriteFile(filepath.Join(WORKING_DIR, "note.txt"), []byte(" a note \n"), 0644), t)
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	conf.BaseURL = "https://example.org/site"
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+	expected := `Jun 1, 2022|/site/x.css|https://example.org/site/x.css|hello-world|<p><em>hi</em></p>` + "\n" +
+		`|Other;Hello World;|A NOTE|<script>var p = ["a","b"];</script>|a, b`
+	if out := readBuilt(t, "index.html"); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
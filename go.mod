@@ -3,15 +3,19 @@ module github.com/wintermute-cell/silvera
 go 1.18
 
 require (
+	github.com/BurntSushi/toml v1.2.0
 	github.com/PuerkitoBio/goquery v1.8.0
 	github.com/abhinav/goldmark-toc v0.2.1
 	github.com/abhinav/goldmark-wikilink v0.3.0
+	github.com/alecthomas/chroma/v2 v2.2.0
 	github.com/litao91/goldmark-mathjax v0.0.0-20210217064022-a43cf739a50f
-	github.com/yuin/goldmark v1.4.12
+	github.com/yuin/goldmark v1.4.15
+	github.com/yuin/goldmark-highlighting/v2 v2.0.0-20230729083705-37449abec8cc
+	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8
 	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
 	github.com/andybalholm/cascadia v1.3.1 // indirect
-	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8 // indirect
+	github.com/dlclark/regexp2 v1.7.0 // indirect
 )
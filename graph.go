@@ -0,0 +1,188 @@
+package main
+
+// IMPORTS
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+//// LINK GRAPH
+// the link graph records which pages of the site link to which other pages.
+// It is collected from the rendered html of each page, so it contains Markdown links,
+// wikilinks and plain html links alike. Templates can access it through {{.OutLinks}} and {{.Backlinks}},
+// and the 'graph' command exports it for use in other tools.
+// -----------------------------------------------------------------------------------
+
+// returns the pages the given page links to, sorted by their path.
+func (page *Page) OutLinks() []*Page {
+	return page.outLinks
+}
+
+// returns the pages that link to the given page, sorted by their path.
+func (page *Page) Backlinks() []*Page {
+	return page.backlinks
+}
+
+// returns the site relative urls of all pages linked from the given html.
+// links to external sites, to other files and to the page itself are ignored.
+func linkedURLs(html []byte, page_url string, known map[string]*Page) []string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(html)))
+	if err != nil {
+		return nil
+	}
+	var urls []string
+	seen := make(map[string]bool)
+	doc.Find("a[href]").Each(func(i int, link *goquery.Selection) {
+		parsed, err := url.Parse(link.AttrOr("href", ""))
+		if err != nil || parsed.Scheme != "" || parsed.Host != "" || parsed.Path == "" {
+			return
+		}
+		target := parsed.Path
+		if !strings.HasPrefix(target, "/") {
+			base := path.Dir(page_url)
+			if strings.HasSuffix(page_url, "/") {
+				base = page_url // with pretty urls, the page is the index of its own directory
+			}
+			target = path.Join(base, target)
+			if strings.HasSuffix(parsed.Path, "/") && target != "/" {
+				target += "/" // path.Join drops the trailing slash of pretty urls
+			}
+		}
+		if _, ok := known[target]; ok && target != page_url && !seen[target] {
+			seen[target] = true
+			urls = append(urls, target)
+		}
+	})
+	return urls
+}
+
+// fills in the out links and backlinks of all pages of the site.
+// the pages have to be sorted by path already, so the links end up sorted the same way.
+func buildLinkGraph(site *Site) {
+	known := make(map[string]*Page)
+	for _, page := range site.Pages {
+		known[page.URL] = page
+		page.outLinks, page.backlinks = nil, nil
+	}
+	for _, page := range site.Pages {
+		for _, target_url := range linkedURLs(page.body, page.URL, known) {
+			target := known[target_url]
+			page.outLinks = append(page.outLinks, target)
+			target.backlinks = append(target.backlinks, page)
+		}
+		sort.Slice(page.outLinks, func(i, j int) bool { return page.outLinks[i].relpath < page.outLinks[j].relpath })
+	}
+}
+
+// these structs describe the link graph in the json export.
+type graphNode struct {
+	Path  string `json:"path"`
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+type graphEdge struct {
+	Source string `json:"source"` // the path of the linking page
+	Target string `json:"target"` // the path of the linked page
+}
+
+type graphExport struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// returns the link graph in the json format.
+func graphToJson(site *Site) ([]byte, error) {
+	export := graphExport{Nodes: []graphNode{}, Edges: []graphEdge{}}
+	for _, page := range site.Pages {
+		export.Nodes = append(export.Nodes, graphNode{Path: page.relpath, URL: page.URL, Title: page.Title})
+		for _, target := range page.outLinks {
+			export.Edges = append(export.Edges, graphEdge{Source: page.relpath, Target: target.relpath})
+		}
+	}
+	return json.MarshalIndent(export, "", "  ")
+}
+
+// returns the link graph in the dot format of graphviz, which can be drawn using 'dot -Tsvg graph.dot > graph.svg'.
+func graphToDot(site *Site) []byte {
+	var b strings.Builder
+	b.WriteString("digraph silvera {\n")
+	for _, page := range site.Pages {
+		fmt.Fprintf(&b, "  %q [label=%q];\n", page.relpath, page.Title)
+	}
+	for _, page := range site.Pages {
+		for _, target := range page.outLinks {
+			fmt.Fprintf(&b, "  %q -> %q;\n", page.relpath, target.relpath)
+		}
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// the 'graph' command loads all pages of the site without building it, and exports the link graph to a file.
+// the graph is not printed, as silvera logs its progress to the standard output.
+func commandGraph(args []string) error {
+	flags := flag.NewFlagSet("graph", flag.ExitOnError)
+	format := flags.String("format", "dot", "the output format, either 'dot' or 'json'")
+	output := flags.String("o", "", "the file to write the graph to (default \"graph.dot\" or \"graph.json\" in the workspace)")
+	var opts BuildOptions
+	addPublishFlags(flags, &opts)
+	flags.Parse(args)
+	if *format != "dot" && *format != "json" {
+		return fmt.Errorf("unknown graph format %q, use 'dot' or 'json'", *format)
+	}
+	if *output == "" {
+		*output = filepath.Join(WORKING_DIR, "graph."+*format)
+	}
+
+	config, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	if err != nil {
+		return err
+	}
+
+	// render all pages, but don't write anything. Rendering problems are reported, but don't stop the export.
+	report := &errorReport{}
+	// pages that are left out of the build are left out of the graph as well
+	published, _ := filterUnpublished(collectJobs(config, report), opts)
+	var jobs []buildJob
+	for _, job := range published {
+		if !job.isDir && strings.HasSuffix(job.relpath, ".md") {
+			jobs = append(jobs, job)
+		}
+	}
+	index := newPageIndex(jobs)
+	site := Site{}
+	for _, job := range jobs {
+		page, err := loadPage(job.srcPath, job.relpath, job.outpath, job.conf, nil, index)
+		if err != nil {
+			report.add(job.srcPath, err)
+			continue
+		}
+		site.Pages = append(site.Pages, page)
+	}
+	sort.Slice(site.Pages, func(i, j int) bool { return site.Pages[i].relpath < site.Pages[j].relpath })
+	buildLinkGraph(&site)
+
+	var out []byte
+	if *format == "json" {
+		if out, err = graphToJson(&site); err != nil {
+			return err
+		}
+	} else {
+		out = graphToDot(&site)
+	}
+	if err := ioutil.WriteFile(*output, out, 0644); err != nil {
+		return err
+	}
+	fmt.Println("graph:", len(site.Pages), "pages ->", *output)
+	return report.err()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLinkGraph(t *testing.T) {
+	setupWorkspace(t, "{{range .OutLinks}}{{.Path}};{{end}}|{{range .Backlinks}}{{.Title}};{{end}}", map[string]string{
+		"index.md":     "# Home\n[a](a.md) [[b]] [again](a.md#top) [self](index.md) [web](https://example.org)",
+		"a.md":         "# A\n[home](index.html)",
+		"sub/b.md":     "# B\n[a](../a.md)",
+		"sub/alone.md": "# Alone",
+	})
+	testerr(commandBuild(nil), t)
+
+	expected := map[string]string{
+		"index.html":     "/a.md;/sub/b.md;|A;",
+		"a.html":         "/index.md;|Home;B;",
+		"sub/b.html":     "/a.md;|Home;",
+		"sub/alone.html": "|",
+	}
+	for name, out := range expected {
+		if built := readBuilt(t, name); built != out {
+			t.Errorf("%s: expected %q, got %q", name, out, built)
+		}
+	}
+
+	// the exports contain the same edges
+	index, a := &Page{Path: "/index.md", relpath: "/index.md", Title: "Home"}, &Page{Path: "/a.md", relpath: "/a.md", Title: "A \"quoted\""}
+	index.outLinks = []*Page{a}
+	site := &Site{Pages: []*Page{a, index}}
+
+	dot := string(graphToDot(site))
+	for _, line := range []string{`"/a.md" [label="A \"quoted\""];`, `"/index.md" -> "/a.md";`} {
+		if !strings.Contains(dot, line) {
+			t.Errorf("expected %s in %q", line, dot)
+		}
+	}
+	data, err := graphToJson(site)
+	testerr(err, t)
+	var export graphExport
+	testerr(json.Unmarshal(data, &export), t)
+	if len(export.Nodes) != 2 || len(export.Edges) != 1 || export.Edges[0] != (graphEdge{Source: "/index.md", Target: "/a.md"}) {
+		t.Errorf("unexpected json export: %s", data)
+	}
+}
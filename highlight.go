@@ -0,0 +1,108 @@
+package main
+
+// IMPORTS
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	gm "github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+)
+
+//// SYNTAX HIGHLIGHTING
+// fenced code blocks can be highlighted while building, using chroma (https://github.com/alecthomas/chroma).
+// This way, no highlighting script has to be shipped to the browser.
+// The colors are either put into style attributes ("inline" mode), or into a separate stylesheet
+// that the template has to link to ("css" mode).
+// -----------------------------------------------------------------------------------
+
+// returns the chroma style with the configured name. Without a name, the "github" style is used.
+func highlightStyle(conf HighlightConf) (*chroma.Style, error) {
+	name := conf.Style
+	if name == "" {
+		name = "github"
+	}
+	style, ok := styles.Registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown syntax highlighting style %q", name)
+	}
+	return style, nil
+}
+
+// returns the chroma html formatter options for the configured mode.
+func highlightFormatOptions(conf HighlightConf) []chromahtml.Option {
+	return []chromahtml.Option{
+		chromahtml.WithClasses(conf.Mode == "css"),
+		chromahtml.WithLineNumbers(conf.LineNumbers),
+	}
+}
+
+// returns the goldmark extension that highlights fenced code blocks, as configured.
+// an unknown style falls back to the default, the error is reported when writing the stylesheet.
+func highlightExtension(conf HighlightConf) gm.Extender {
+	style, err := highlightStyle(conf)
+	if err != nil {
+		style = styles.Fallback
+	}
+	return highlighting.NewHighlighting(
+		highlighting.WithCustomStyle(style),
+		highlighting.WithFormatOptions(highlightFormatOptions(conf)...),
+	)
+}
+
+// returns the stylesheet with the colors of the configured style, for use in "css" mode.
+func buildHighlightStylesheet(conf HighlightConf) ([]byte, error) {
+	style, err := highlightStyle(conf)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := chromahtml.New(highlightFormatOptions(conf)...).WriteCSS(&buf, style); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// checks the highlighting config of the global config, and writes the stylesheet to the outdir if it is used.
+func writeHighlightStylesheet(config Config, report *errorReport) {
+	conf := config.Extensions.SyntaxHighlighting
+	if !conf.Enabled {
+		return
+	}
+	if conf.Mode != "" && conf.Mode != "inline" && conf.Mode != "css" {
+		report.add(filepath.Join(WORKING_DIR, "silvera.conf"), fmt.Errorf("unknown syntax highlighting mode %q, use 'inline' or 'css'", conf.Mode))
+		return
+	}
+	if _, err := highlightStyle(conf); err != nil {
+		report.add(filepath.Join(WORKING_DIR, "silvera.conf"), err)
+		return
+	}
+	if conf.Mode != "css" {
+		return // with inline styles, there is no need for a stylesheet
+	}
+
+	stylesheet := conf.Stylesheet
+	if stylesheet == "" {
+		stylesheet = "syntax.css"
+	}
+	stylesheet_path := filepath.Join(config.Outdir, filepath.FromSlash(stylesheet))
+	css, err := buildHighlightStylesheet(conf)
+	if err == nil {
+		// the directory of the stylesheet may not exist in 'src', so it isn't created by the build
+		err = os.MkdirAll(filepath.Dir(stylesheet_path), 0755)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(stylesheet_path, css, 0644)
+	}
+	if err != nil {
+		report.add(stylesheet_path, err)
+		return
+	}
+	fmt.Println("stylesheet:", stylesheet_path)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyntaxHighlighting(t *testing.T) {
+	setupWorkspace(t, "{{.Body}}", map[string]string{
+		"index.md": "# Code\n```go\nfunc main() {}\n```\n",
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+
+	// by default, code blocks are left as they are
+	testerr(commandBuild(nil), t)
+	if out := readBuilt(t, "index.html"); !strings.Contains(out, `<code class="language-go">`) {
+		t.Errorf("expected an unhighlighted code block, got %q", out)
+	}
+
+	// inline mode puts the colors right into the html
+	conf.Extensions.SyntaxHighlighting = HighlightConf{Enabled: true, Style: "monokai", Mode: "inline", LineNumbers: true}
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+	out := readBuilt(t, "index.html")
+	if !strings.Contains(out, `<pre tabindex="0" style="`) || !strings.Contains(out, `<span style="color:#66d9ef">func</span>`) {
+		t.Errorf("expected inline styles, got %q", out)
+	}
+	if !strings.Contains(out, `color:#7f7f7f">1</span>`) {
+		t.Errorf("expected line numbers, got %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(WORKING_DIR, "build", "syntax.css")); err == nil {
+		t.Error("stylesheet written in inline mode")
+	}
+
+	// css mode uses classes, and writes the stylesheet to the configured path
+	conf.Extensions.SyntaxHighlighting = HighlightConf{Enabled: true, Style: "monokai", Mode: "css", Stylesheet: "css/code.css"}
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+	out = readBuilt(t, "index.html")
+	if !strings.Contains(out, `<pre tabindex="0" class="chroma">`) || !strings.Contains(out, `<span class="kd">func</span>`) {
+		t.Errorf("expected classes, got %q", out)
+	}
+	if css := readBuilt(t, "css/code.css"); !strings.Contains(css, ".chroma .kd { color: #66d9ef }") {
+		t.Errorf("unexpected stylesheet: %q", css)
+	}
+
+	// unknown styles make the build fail
+	conf.Extensions.SyntaxHighlighting.Style = "no-such-style"
+	writeConfig(t, conf)
+	var errs BuildErrors
+	if err := commandBuild(nil); !errors.As(err, &errs) || !strings.Contains(errs[0].Error(), "no-such-style") {
+		t.Errorf("expected an error about the unknown style, got %v", err)
+	}
+}
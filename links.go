@@ -0,0 +1,413 @@
+package main
+
+// IMPORTS
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gmwiki "github.com/abhinav/goldmark-wikilink"
+	"github.com/yuin/goldmark/ast"
+	gmp "github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/net/html"
+)
+
+//// LINKS
+// the following types and functions deal with links between the pages of the site.
+// Markdown sources link to each other using their '.md' paths, which don't exist in the build.
+// These links are rewritten to point to the built '.html' files instead.
+// [[wikilinks]] name a page by its filename, title or alias instead, and are resolved the same way.
+// -----------------------------------------------------------------------------------
+
+// unresolved wikilinks are marked with this CSS class, so templates can style them.
+const WIKILINK_MISSING_CLASS = "wikilink-missing"
+
+// this struct holds what the page index knows about a single page.
+type indexedPage struct {
+	srcPath string   // the absolute path of the '.md' source
+	url     string   // the site relative url of the built page
+	name    string   // the filename, without the '.md' extension
+	title   string   // the title given in the front matter, if any
+	aliases []string // the aliases given in the front matter, if any
+}
+
+// this struct knows where every Markdown source of the site ends up in the build.
+// it is collected after the walk through the source directory, before any page is rendered.
+type pageIndex struct {
+	urls  map[string]string // the site relative url of each page, by the absolute path of its '.md' source
+	pages []indexedPage     // all pages, sorted by url
+	hash  string            // a hash of the index, so the build cache notices when pages are added, removed or renamed
+}
+
+// builds the page index from the Markdown files among the given jobs.
+// the front matter of each file is read, so wikilinks can refer to titles and aliases.
+// files that can't be read are still indexed by their filename, the error is reported when the page is loaded.
+func newPageIndex(jobs []buildJob) *pageIndex {
+	index := &pageIndex{urls: make(map[string]string)}
+	for _, job := range jobs {
+		if !strings.HasSuffix(job.relpath, ".md") {
+			continue
+		}
+		page := indexedPage{
+			srcPath: filepath.Clean(job.srcPath),
+			url:     pageURL(job.relpath, job.conf),
+			name:    strings.TrimSuffix(filepath.Base(job.relpath), ".md"),
+		}
+		if file_bytes, err := ioutil.ReadFile(job.srcPath); err == nil {
+			if params, _, err := splitFrontMatter(file_bytes); err == nil {
+				if title, ok := params["title"].(string); ok {
+					page.title = title
+				}
+				page.aliases = stringList(params["aliases"])
+			}
+		}
+		index.urls[page.srcPath] = page.url
+		index.pages = append(index.pages, page)
+	}
+	sort.Slice(index.pages, func(i, j int) bool { return index.pages[i].url < index.pages[j].url })
+
+	var parts [][]byte
+	for _, page := range index.pages {
+		parts = append(parts, []byte(page.srcPath), []byte(page.url), []byte(page.title), []byte(strings.Join(page.aliases, "\n")))
+	}
+	index.hash = hashParts(parts...)
+	return index
+}
+
+// turns a front matter value that is either a single string or a list of strings into a slice.
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// normalizes a page name, so that "My Page", "my-page" and "my_page" all refer to the same page.
+func normalizePageName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "-", "_", "-").Replace(name)
+}
+
+// returns the url of the page a wikilink target refers to, as seen from the file at src_path.
+// targets containing a slash are paths, relative to the current file or to the source directory.
+// all other targets are matched against the filenames, then the titles, then the aliases of all pages.
+// if several pages match, one in the same directory as the current file is preferred.
+func (index *pageIndex) resolveWikilink(src_path string, target string) (string, bool) {
+	target = strings.TrimSuffix(strings.TrimSpace(target), ".md")
+	if strings.Contains(target, "/") {
+		bases := []string{filepath.Dir(src_path), SOURCE_DIR}
+		if strings.HasPrefix(target, "/") {
+			bases = []string{SOURCE_DIR}
+		}
+		for _, base := range bases {
+			if url, ok := index.urls[filepath.Join(base, filepath.FromSlash(target)+".md")]; ok {
+				return url, true
+			}
+		}
+		return "", false
+	}
+
+	key := normalizePageName(target)
+	matchers := []func(page indexedPage) bool{
+		func(page indexedPage) bool { return normalizePageName(page.name) == key },
+		func(page indexedPage) bool { return page.title != "" && normalizePageName(page.title) == key },
+		func(page indexedPage) bool {
+			for _, alias := range page.aliases {
+				if normalizePageName(alias) == key {
+					return true
+				}
+			}
+			return false
+		},
+	}
+	for _, matches := range matchers {
+		found := ""
+		for _, page := range index.pages {
+			if !matches(page) {
+				continue
+			}
+			if filepath.Dir(page.srcPath) == filepath.Dir(src_path) {
+				return page.url, true
+			}
+			if found == "" {
+				found = page.url
+			}
+		}
+		if found != "" {
+			return found, true
+		}
+	}
+	return "", false
+}
+
+// reports whether pages are written as 'index.html' files in a directory of their own, see 'url_style'.
+func prettyURLs(conf Config) bool {
+	return conf.URLStyle == URL_STYLE_PRETTY
+}
+
+// returns the site relative url of the page built from the '.md' file at relpath.
+// with pretty urls, "/blog/post.md" becomes "/blog/post/", and "/blog/index.md" becomes "/blog/".
+// otherwise, they become "/blog/post.html" and "/blog/index.html".
+func pageURL(relpath string, conf Config) string {
+	url := strings.TrimSuffix(filepath.ToSlash(relpath), ".md")
+	if !prettyURLs(conf) {
+		return url + ".html"
+	}
+	if path.Base(url) == "index" {
+		return strings.TrimSuffix(url, "index")
+	}
+	return url + "/"
+}
+
+// returns the path of the page built from the '.md' file at relpath, as templates see it in {{.Path}}.
+// with pretty urls, "/blog/post.md" becomes "/blog/post/index.md", matching the place the page is written to.
+// indexes and all pages built with the default url style keep the path of their source.
+func pagePath(relpath string, conf Config) string {
+	if !prettyURLs(conf) || filepath.Base(relpath) == "index.md" {
+		return relpath
+	}
+	return filepath.Join(strings.TrimSuffix(relpath, ".md"), "index.md")
+}
+
+// returns the path in the build directory the page built from the '.md' file at outpath is written to.
+// with pretty urls, "build/blog/post.md" becomes "build/blog/post/index.html", otherwise "build/blog/post.html".
+func pageOutPath(outpath string, conf Config) string {
+	name := strings.TrimSuffix(outpath, ".md")
+	if !prettyURLs(conf) || filepath.Base(name) == "index" {
+		return name + ".html"
+	}
+	return filepath.Join(name, "index.html")
+}
+
+// returns the path in the build directory the given job writes to.
+func jobOutPath(job buildJob) string {
+	if strings.HasSuffix(job.relpath, ".md") {
+		return pageOutPath(job.outpath, job.conf)
+	}
+	return job.outpath
+}
+
+// reports jobs that would be written to the same place as an earlier job, and removes them from the build.
+// with pretty urls, this happens to "blog/post.md" and "blog/post/index.md", which both become "build/blog/post/index.html".
+func dropCollisions(jobs []buildJob, report *errorReport) []buildJob {
+	written_by := make(map[string]string) // the relpath of the job writing to each path in the build directory
+	var kept []buildJob
+	for _, job := range jobs {
+		if job.isDir {
+			kept = append(kept, job)
+			continue
+		}
+		out_path := jobOutPath(job)
+		if other, ok := written_by[out_path]; ok {
+			report.add(job.srcPath, fmt.Errorf("would be written to %s, which %s is written to already", out_path, other))
+			continue
+		}
+		written_by[out_path] = job.relpath
+		kept = append(kept, job)
+	}
+	return kept
+}
+
+// returns a relative url leading from the page at from_url to to_url. Both are site relative urls.
+// urls ending in a slash (see pretty urls) refer to the directory itself, so the relative url keeps the slash.
+func relativeURL(from_url string, to_url string) string {
+	from_dir := path.Dir(from_url)
+	if strings.HasSuffix(from_url, "/") {
+		from_dir = from_url
+	}
+	rel, err := filepath.Rel(filepath.FromSlash(from_dir), filepath.FromSlash(to_url))
+	if err != nil {
+		return to_url
+	}
+	rel = filepath.ToSlash(rel)
+	if strings.HasSuffix(to_url, "/") && !strings.HasSuffix(rel, "/") {
+		rel += "/"
+	}
+	return rel
+}
+
+// rewrites the relative references in a piece of html rendered for the page at page_url, like "about.html" or "#intro".
+// the elements and attributes are the ones the link checker looks at (see checkedAttributes).
+// fn receives the site relative url each reference resolves to (like "/blog/about.html") and the reference itself,
+// and returns the new reference. Links to other sites and site relative links are left as they are,
+// and so is all other html: only the changed tags are written anew.
+func rewriteRelativeLinks(html_bytes []byte, page_url string, fn func(site_url string, ref string) string) []byte {
+	base, err := url.Parse(page_url)
+	if err != nil {
+		return html_bytes
+	}
+	var out bytes.Buffer
+	tokenizer := html.NewTokenizer(bytes.NewReader(html_bytes))
+	for {
+		token_type := tokenizer.Next()
+		if token_type == html.ErrorToken {
+			break // the end of the html
+		}
+		raw := append([]byte(nil), tokenizer.Raw()...) // the raw bytes change once the token is parsed
+		if token_type != html.StartTagToken && token_type != html.SelfClosingTagToken {
+			out.Write(raw)
+			continue
+		}
+
+		token := tokenizer.Token()
+		changed := false
+		for i, attr := range token.Attr {
+			if attr.Key != checkedAttributes[token.Data] || attr.Val == "" {
+				continue
+			}
+			ref, err := url.Parse(attr.Val)
+			if err != nil || ref.Scheme != "" || ref.Host != "" || strings.HasPrefix(ref.Path, "/") {
+				continue
+			}
+			if rewritten := fn(base.ResolveReference(ref).String(), attr.Val); rewritten != attr.Val {
+				token.Attr[i].Val = rewritten
+				changed = true
+			}
+		}
+		if changed {
+			out.WriteString(token.String())
+		} else {
+			out.Write(raw)
+		}
+	}
+	return out.Bytes()
+}
+
+// this goldmark AST transformer rewrites links to '.md' sources into links to the built pages,
+// and resolves wikilinks using the page index. Other relative links and images are adjusted with pretty urls.
+// links to sources that don't exist are left as they are, and a warning is recorded.
+type linkTransformer struct {
+	srcPath  string     // the absolute path of the file that is being rendered
+	conf     Config     // the configuration that applies to the file
+	index    *pageIndex // the index of all pages of the site
+	warnings *[]string  // warnings about broken links are appended here
+}
+
+// rewrites a single link destination, like "../dir/page.md#section".
+// returns the new destination, and whether it should be replaced at all.
+func (t *linkTransformer) rewrite(dest string) (string, bool) {
+	parsed, err := url.Parse(dest)
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" || !strings.HasSuffix(parsed.Path, ".md") {
+		return "", false // not a link to a local Markdown file
+	}
+
+	// a leading slash refers to the source directory, everything else is relative to the current file
+	var target string
+	if strings.HasPrefix(parsed.Path, "/") {
+		target = filepath.Join(SOURCE_DIR, filepath.FromSlash(parsed.Path))
+	} else {
+		target = filepath.Join(filepath.Dir(t.srcPath), filepath.FromSlash(parsed.Path))
+	}
+
+	target_url, ok := t.index.urls[target]
+	if !ok {
+		*t.warnings = append(*t.warnings, fmt.Sprintf("link to missing page %s", dest))
+		return "", false
+	}
+	rewritten := relativeURL(t.index.urls[filepath.Clean(t.srcPath)], target_url)
+	if parsed.RawQuery != "" {
+		rewritten += "?" + parsed.RawQuery
+	}
+	if parsed.Fragment != "" {
+		rewritten += "#" + parsed.EscapedFragment()
+	}
+	return rewritten, true
+}
+
+// rewrites a relative link destination that doesn't lead to a Markdown file, like "pic.png" or "../files/talk.pdf".
+// such links are relative to the source directory of the page. With pretty urls, pages other than indexes are
+// written into a directory of their own (see pageOutPath), so the links are made relative to that directory instead.
+// returns the new destination, and whether it should be replaced at all.
+func (t *linkTransformer) relocate(dest string) (string, bool) {
+	if !prettyURLs(t.conf) || filepath.Base(t.srcPath) == "index.md" {
+		return "", false // the page is written next to its source, so the links work as they are
+	}
+	parsed, err := url.Parse(dest)
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" || parsed.Path == "" ||
+		strings.HasPrefix(parsed.Path, "/") || strings.HasSuffix(parsed.Path, ".md") {
+		return "", false // not a relative link, or a link to a Markdown file, which rewrite handles
+	}
+	rel_src, err := filepath.Rel(SOURCE_DIR, t.srcPath)
+	if err != nil {
+		return "", false
+	}
+	base, err := url.Parse("/" + filepath.ToSlash(rel_src))
+	if err != nil {
+		return "", false
+	}
+	target := base.ResolveReference(parsed)
+	target.Path = relativeURL(t.index.urls[filepath.Clean(t.srcPath)], target.Path)
+	return target.String(), true
+}
+
+// replaces a wikilink node with a regular link to the page it refers to.
+// unresolved wikilinks keep the destination they had before (the target name with '.html' appended,
+// or '/' with pretty urls), and are marked with WIKILINK_MISSING_CLASS.
+func (t *linkTransformer) replaceWikilink(node *gmwiki.Node) {
+	link := ast.NewLink()
+	if len(node.Target) > 0 { // a link like [[#heading]] points to the current page, so it has no target
+		if target_url, ok := t.index.resolveWikilink(t.srcPath, string(node.Target)); ok {
+			link.Destination = []byte(relativeURL(t.index.urls[filepath.Clean(t.srcPath)], target_url))
+		} else {
+			*t.warnings = append(*t.warnings, fmt.Sprintf("unresolved wikilink [[%s]]", node.Target))
+			link.Destination = []byte(string(node.Target) + ".html")
+			if prettyURLs(t.conf) {
+				link.Destination = []byte(string(node.Target) + "/")
+			}
+			link.SetAttributeString("class", []byte(WIKILINK_MISSING_CLASS))
+		}
+	}
+
+	// headings get their ids the same way goldmarks auto heading id option generates them
+	if len(node.Fragment) > 0 {
+		link.Destination = append(link.Destination, '#')
+		link.Destination = append(link.Destination, gmp.NewContext().IDs().Generate(node.Fragment, ast.KindHeading)...)
+	}
+	for child := node.FirstChild(); child != nil; child = node.FirstChild() {
+		link.AppendChild(link, child)
+	}
+	node.Parent().ReplaceChild(node.Parent(), node, link)
+}
+
+// Transform implements goldmarks parser.ASTTransformer interface.
+func (t *linkTransformer) Transform(doc *ast.Document, reader text.Reader, pc gmp.Context) {
+	var wikilinks []*gmwiki.Node // nodes can't be replaced while walking the tree, so they are collected first
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.Link:
+			if dest, ok := t.rewrite(string(n.Destination)); ok {
+				n.Destination = []byte(dest)
+			} else if dest, ok := t.relocate(string(n.Destination)); ok {
+				n.Destination = []byte(dest)
+			}
+		case *ast.Image:
+			if dest, ok := t.relocate(string(n.Destination)); ok {
+				n.Destination = []byte(dest)
+			}
+		case *gmwiki.Node:
+			wikilinks = append(wikilinks, n)
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, node := range wikilinks {
+		t.replaceWikilink(node)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMdLinks(t *testing.T) {
+	setupWorkspace(t, "{{.Body}}", map[string]string{
+		"index.md":      "[a](blog/post.md) [b](/about.md#team) [c](https://example.org/x.md) [d](missing.md)",
+		"about.md":      "# About",
+		"blog/post.md":  "[home](../index.md) [about](../about.md#team) [self](post.md?x=1#top)",
+		"blog/notes.md": "[post](./post.md) [image](image.png)",
+	})
+	testerr(commandBuild(nil), t)
+
+	expected := map[string][]string{
+		"index.html": {
+			`<a href="blog/post.html">a</a>`,
+			`<a href="about.html#team">b</a>`,
+			`<a href="https://example.org/x.md">c</a>`,
+			`<a href="missing.md">d</a>`, // missing pages are left as they are
+		},
+		"blog/post.html": {
+			`<a href="../index.html">home</a>`,
+			`<a href="../about.html#team">about</a>`,
+			`<a href="post.html?x=1#top">self</a>`,
+		},
+		"blog/notes.html": {
+			`<a href="post.html">post</a>`,
+			`<a href="image.png">image</a>`,
+		},
+	}
+	for name, links := range expected {
+		out := readBuilt(t, name)
+		for _, link := range links {
+			if !strings.Contains(out, link) {
+				t.Errorf("%s: expected %s in %q", name, link, out)
+			}
+		}
+	}
+}
+
+func TestMdLinkWarnings(t *testing.T) {
+	setupWorkspace(t, "{{.Body}}", map[string]string{
+		"index.md": "[a](missing.md) [b](other.md)",
+		"other.md": "# Other",
+	})
+	index := newPageIndex([]buildJob{
+		{srcPath: filepath.Join(SOURCE_DIR, "index.md"), relpath: "/index.md"},
+		{srcPath: filepath.Join(SOURCE_DIR, "other.md"), relpath: "/other.md"},
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	rendered, err := renderMdToHtml(filepath.Join(SOURCE_DIR, "index.md"), conf, index)
+	testerr(err, t)
+	if len(rendered.warnings) != 1 || !strings.Contains(rendered.warnings[0], "missing.md") {
+		t.Errorf("expected a single warning about missing.md, got %v", rendered.warnings)
+	}
+}
+
+func TestWikilinks(t *testing.T) {
+	setupWorkspace(t, "{{.Body}}", map[string]string{
+		"index.md":           "[[Getting Started]] [[Intro Post|the intro]] [[faq#Who Are You]] [[docs/setup]] [[#Top]] [[nowhere]]",
+		"docs/setup.md":      "---\ntitle: Getting Started\n---\n# Setup",
+		"docs/faq.md":        "# FAQ",
+		"blog/2022-intro.md": "---\naliases: [intro post]\n---\n[[setup]] [[faq]]",
+		"blog/faq.md":        "# Blog FAQ",
+	})
+	testerr(commandBuild(nil), t)
+
+	expected := map[string][]string{
+		"index.html": {
+			`<a href="docs/setup.html">Getting Started</a>`,           // by title
+			`<a href="blog/2022-intro.html">the intro</a>`,            // by alias, with a label
+			`<a href="blog/faq.html#who-are-you">faq#Who Are You</a>`, // by filename, the first match by path
+			`<a href="docs/setup.html">docs/setup</a>`,                // by path
+			`<a href="#top">#Top</a>`,                                 // a heading on the same page
+			`<a href="nowhere.html" class="wikilink-missing">`,        // unresolved
+		},
+		"blog/2022-intro.html": {
+			`<a href="../docs/setup.html">setup</a>`,
+			`<a href="faq.html">faq</a>`, // pages in the same directory are preferred
+		},
+	}
+	for name, links := range expected {
+		out := readBuilt(t, name)
+		for _, link := range links {
+			if !strings.Contains(out, link) {
+				t.Errorf("%s: expected %s in %q", name, link, out)
+			}
+		}
+	}
+}
+
+func TestPrettyURLs(t *testing.T) {
+	setupWorkspace(t, "{{.URL}}|{{range .Backlinks}}{{.URL}};{{end}}|{{.Body}}", map[string]string{
+		"index.md":      "[Post](blog/post.md) [[post]] [[nowhere]]",
+		"blog/index.md": "[Post](post.md?query#part)",
+		"blog/post.md":  "# Part\n[Home](../index.md) [Blog](index.md)",
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	conf.URLStyle = "pretty"
+	conf.ParserOptions.WithAutoHeadingID = true
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+
+	expected := map[string]string{
+		"index.html":           `/|/blog/post/;|<p><a href="blog/post/">Post</a> <a href="blog/post/">post</a> <a href="nowhere/" class="wikilink-missing">nowhere</a></p>` + "\n",
+		"blog/index.html":      `/blog/|/blog/post/;|<p><a href="post/?query#part">Post</a></p>` + "\n",
+		"blog/post/index.html": `/blog/post/|/blog/;/;|<h1 id="part">Part</h1>` + "\n" + `<p><a href="../../">Home</a> <a href="../">Blog</a></p>` + "\n",
+	}
+	for name, out := range expected {
+		if built := readBuilt(t, name); built != out {
+			t.Errorf("%s: expected %q, got %q", name, out, built)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(WORKING_DIR, "build", "blog", "post.html")); err == nil {
+		t.Error("page written with its '.html' name")
+	}
+
+	// the link checker finds the pages at their new place, so only the unresolved wikilink is broken
+	err = commandCheck(nil)
+	if err == nil || strings.Count(err.Error(), "broken") != 1 || !strings.Contains(err.Error(), `broken link "nowhere/"`) {
+		t.Errorf("expected only the unresolved wikilink to be broken, got %v", err)
+	}
+
+	conf.URLStyle = "fancy"
+	writeConfig(t, conf)
+	if err := commandBuild(nil); err == nil || !strings.Contains(err.Error(), `unknown url_style "fancy"`) {
+		t.Errorf("expected an error for an unknown url_style, got %v", err)
+	}
+}
+
+func TestPrettyURLPaths(t *testing.T) {
+	setupWorkspace(t, "{{.Path}}|{{range .Site.Pages}}{{.Path}};{{end}}", map[string]string{
+		"index.md":      "",
+		"blog/index.md": "",
+		"blog/post.md":  "",
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+
+	// with the default url style, {{.Path}} is the path of the source
+	testerr(commandBuild(nil), t)
+	if built := readBuilt(t, "blog/post.html"); built != "/blog/post.md|/blog/index.md;/blog/post.md;/index.md;" {
+		t.Errorf("unexpected paths: %q", built)
+	}
+
+	// with pretty urls, pages other than indexes are placed in a directory of their own
+	conf.URLStyle = "pretty"
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+	expected := map[string]string{
+		"index.html":           "/index.md|/blog/index.md;/blog/post/index.md;/index.md;",
+		"blog/index.html":      "/blog/index.md|/blog/index.md;/blog/post/index.md;/index.md;",
+		"blog/post/index.html": "/blog/post/index.md|/blog/index.md;/blog/post/index.md;/index.md;",
+	}
+	for name, out := range expected {
+		if built := readBuilt(t, name); built != out {
+			t.Errorf("%s: expected %q, got %q", name, out, built)
+		}
+	}
+}
+
+func TestPrettyURLCollisions(t *testing.T) {
+	setupWorkspace(t, "{{.Body}}", map[string]string{
+		"blog/post.md":       "post",
+		"blog/post/index.md": "post index",
+		"about.md":           "about",
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	conf.URLStyle = "pretty"
+	writeConfig(t, conf)
+
+	err = commandBuild(nil)
+	if err == nil || strings.Count(err.Error(), "would be written to") != 1 ||
+		!strings.Contains(err.Error(), filepath.Join("src", "blog", "post.md")) ||
+		!strings.Contains(err.Error(), "/blog/post/index.md is written to already") {
+		t.Errorf("expected an error for the colliding pages, got %v", err)
+	}
+	if built := readBuilt(t, "blog/post/index.html"); built != "<p>post index</p>\n" {
+		t.Errorf("expected the first page to be built, got %q", built)
+	}
+	readBuilt(t, "about/index.html") // other pages are built anyway
+
+	// with the default url style, the pages don't collide
+	conf.URLStyle = "html"
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+}
+
+func TestPrettyURLAssets(t *testing.T) {
+	setupWorkspace(t, "{{.Body}}", map[string]string{
+		"blog/about.md":       "![](pic.png) [talk](files/talk.pdf?download) [logo](../logo.png) [blog](./) [site](/logo.png)",
+		"blog/index.md":       "![](pic.png)",
+		"blog/pic.png":        "pixels",
+		"blog/files/talk.pdf": "slides",
+		"logo.png":            "pixels",
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	conf.URLStyle = "pretty"
+	conf.CheckLinks = true
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t) // the link check finds the files from the directories of the pages
+
+	expected := map[string]string{
+		"blog/about/index.html": `<p><img src="../pic.png" alt="" /> <a href="../files/talk.pdf?download">talk</a> <a href="../../logo.png">logo</a> <a href="../">blog</a> <a href="/logo.png">site</a></p>` + "\n",
+		"blog/index.html":       `<p><img src="pic.png" alt="" /></p>` + "\n",
+	}
+	for name, out := range expected {
+		if built := readBuilt(t, name); built != out {
+			t.Errorf("%s: expected %q, got %q", name, out, built)
+		}
+	}
+}
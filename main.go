@@ -3,13 +3,21 @@ package main
 // IMPORTS
 import (
 	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"html/template"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
-	"text/template"
+	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	gmtoc "github.com/abhinav/goldmark-toc"
@@ -20,6 +28,7 @@ import (
 	gmp "github.com/yuin/goldmark/parser"
 	gmr "github.com/yuin/goldmark/renderer"
 	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
 	"gopkg.in/yaml.v2"
 )
 
@@ -39,6 +48,17 @@ type Exts struct {
 	Wikilink        bool `yaml:"wikilink"`
 	Mathjax         bool `yaml:"mathjax"`
 	TableOfContents bool `yaml:"table_of_contents"`
+
+	SyntaxHighlighting HighlightConf `yaml:"syntax_highlighting"`
+}
+
+// this struct contains the user config values regarding the highlighting of fenced code blocks.
+type HighlightConf struct {
+	Enabled     bool   `yaml:"enabled"`      // highlight code blocks while building
+	Style       string `yaml:"style"`        // the name of a chroma style, like "monokai". defaults to "github".
+	LineNumbers bool   `yaml:"line_numbers"` // show line numbers next to the code
+	Mode        string `yaml:"mode"`         // "inline" puts the colors in style attributes, "css" uses classes and a stylesheet
+	Stylesheet  string `yaml:"stylesheet"`   // where the stylesheet is written to in "css" mode, relative to the outdir (only read from the global config)
 }
 
 // this struct contains the user config values regarding parser options.
@@ -54,28 +74,160 @@ type RendererOpts struct {
 	WithUnsafe    bool `yaml:"unsafe_rendering"`
 }
 
+// this struct contains the user config values regarding a single generated feed.
+type FeedConf struct {
+	Source      string   `yaml:"source"`      // the directory in 'src' whose pages are listed, like "blog" (or "src/blog")
+	Output      string   `yaml:"output"`      // the directory in the 'outdir' the feeds are written to. defaults to the source directory.
+	Title       string   `yaml:"title"`       // the title of the feed
+	Description string   `yaml:"description"` // a short description of the feed
+	Author      string   `yaml:"author"`      // the default author of the items
+	Formats     []string `yaml:"formats"`     // any of "rss", "atom" and "json". all of them if empty.
+	Limit       int      `yaml:"limit"`       // the maximum number of items, newest first. all pages if 0.
+}
+
+// this struct contains the user config values regarding a single taxonomy, like "tags".
+type TaxonomyConf struct {
+	Name         string `yaml:"name"`          // the front matter field holding the terms, like "tags"
+	Output       string `yaml:"output"`        // the directory the pages are written to, relative to the outdir. defaults to the name.
+	Template     string `yaml:"template"`      // the template of the index page of each term. defaults to the template of the site.
+	ListTemplate string `yaml:"list_template"` // the template of the overview page listing all terms. defaults to the template of the site.
+}
+
+// this struct contains the user config values regarding the table of contents of each page.
+type TOCConf struct {
+	MinDepth int `yaml:"min_depth"` // the lowest heading level listed, like 2 for h2. defaults to 1.
+	MaxDepth int `yaml:"max_depth"` // the highest heading level listed, like 3 for h3. defaults to 6.
+}
+
+// this struct contains the user config values regarding split listing pages.
+type PaginationConf struct {
+	PageSize int `yaml:"page_size"` // the number of pages listed on each page of a listing. defaults to 10.
+}
+
+// this struct contains the user config values regarding the generated sitemap and robots.txt.
+type SitemapConf struct {
+	Enabled    bool     `yaml:"enabled"`    // generate a sitemap.xml (only read from the global config)
+	Exclude    bool     `yaml:"exclude"`    // leave the pages this config applies to out of the sitemap
+	ChangeFreq string   `yaml:"changefreq"` // the 'changefreq' of the pages, like "weekly". left out if empty.
+	Robots     bool     `yaml:"robots"`     // also generate a robots.txt pointing to the sitemap (only read from the global config)
+	Disallow   []string `yaml:"disallow"`   // paths that robots.txt disallows, like "/drafts/"
+}
+
 // this struct holds the entire user config, once parsed from the yaml file.
 // it is compromised of several structs defined above.
 type Config struct {
-	Outdir          string       `yaml:"outdir"`
-	Templatedir     string       `yaml:"template"`
-	Extensions      Exts         `yaml:"extensions"`
-	ParserOptions   ParserOpts   `yaml:"parser_options"`
-	RendererOptions RendererOpts `yaml:"renderer_options"`
-	Addons          []string     `yaml:"addons"`
+	Outdir          string         `yaml:"outdir"`
+	Templatedir     string         `yaml:"template"`
+	BaseURL         string         `yaml:"base_url"`
+	URLStyle        string         `yaml:"url_style"` // URL_STYLE_HTML (the default) or URL_STYLE_PRETTY
+	Extensions      Exts           `yaml:"extensions"`
+	ParserOptions   ParserOpts     `yaml:"parser_options"`
+	RendererOptions RendererOpts   `yaml:"renderer_options"`
+	Addons          []string       `yaml:"addons"`
+	Feeds           []FeedConf     `yaml:"feeds"`
+	Sitemap         SitemapConf    `yaml:"sitemap"`
+	CheckLinks      bool           `yaml:"check_links"`
+	Taxonomies      []TaxonomyConf `yaml:"taxonomies"`
+	TOC             TOCConf        `yaml:"toc"`
+	Pagination      PaginationConf `yaml:"pagination"`
+}
+
+//// SITE STRUCTS
+// the following structs hold the data that is collected about the site during the build,
+// and that is then made available to the templates.
+// -----------------------------------------------------------------------------------
+
+// this struct holds everything that is known about a single Markdown page.
+// the exported fields can be accessed from within templates, like {{range .Site.Pages}}{{.Title}}{{end}}.
+type Page struct {
+	Path   string                 // the relative path of the page, like "/blog/post.md" ("/blog/post/index.md" with pretty urls)
+	URL    string                 // the relative path of the built file, like "/blog/post.html"
+	Title  string                 // the front matter title, or the first h1 element of the page
+	Params map[string]interface{} // the front matter of the page
+	Date   time.Time              // the front matter date, or the zero time if there is none
+
+	Summary     template.HTML // a short summary of the page, see summary.go
+	WordCount   int           // the number of words on the page
+	ReadingTime int           // the estimated reading time in minutes
+	TOC         *TOC          // the headings of the page, see toc.go
+
+	relpath   string    // the relative path of the source file, like "/blog/post.md", whatever the url style is
+	srcPath   string    // the absolute path of the source file
+	outPath   string    // the path the built file is written to
+	conf      Config    // the configuration that applies to this page
+	template  string    // the path of the template the page is embedded in
+	body      []byte    // the html generated from the Markdown source
+	inputHash string    // the hash of the source and its config, see the build cache
+	modTime   time.Time // the time the source file was last modified
+	warnings  []string  // problems found while rendering the page
+	outLinks  []*Page   // the pages this page links to, see the link graph
+	backlinks []*Page   // the pages linking to this page
+
+	paginators []*Paginator // for listing pages, the pages the listing is split into
+}
+
+// returns the front matter date of the page, or if there is none, the time its source was last modified.
+func (page *Page) lastModified() time.Time {
+	if !page.Date.IsZero() {
+		return page.Date
+	}
+	return page.modTime
+}
+
+// this struct holds data about the whole site, collected in the first pass of the build.
+type Site struct {
+	Pages      []*Page              // all pages of the site, sorted by the path of their source
+	Taxonomies map[string]*Taxonomy // the taxonomies of the site by name, like {{.Site.Taxonomies.tags}}
+}
+
+// returns the pages of the site sorted by date, newest first.
+// this is useful for "recent posts" lists, like {{range .Site.PagesByDate}}...{{end}}.
+func (site *Site) PagesByDate() []*Page {
+	pages := make([]*Page, len(site.Pages))
+	copy(pages, site.Pages)
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].Date.After(pages[j].Date)
+	})
+	return pages
+}
+
+// this struct holds the data to be embedded into to template.
+type EmbeddableContents struct {
+	Title     string
+	Body      template.HTML
+	Path      string // the relative path of the page, following the 'url_style'
+	URL       string // the site relative url of the page, following the 'url_style'
+	Params    map[string]interface{}
+	Site      *Site
+	Data      map[string]interface{} // the contents of the data files
+	OutLinks  []*Page                // the pages this page links to
+	Backlinks []*Page                // the pages that link to this page
+	Taxonomy  *Taxonomy              // on generated taxonomy pages, the taxonomy that is listed
+	Term      *Term                  // on generated term pages, the term that is listed
+
+	Summary     template.HTML // a short summary of the page, see summary.go
+	WordCount   int           // the number of words on the page
+	ReadingTime int           // the estimated reading time in minutes
+	TOC         *TOC          // the headings of the page, see toc.go
+	Paginator   *Paginator    // on listing pages, the current page of the listing, see pagination.go
 }
 
 // GLOBAL CONSTANTS
 const (
 	HIDDEN_DIR = ".slv"
 	PRINT_HOOK = false
+
+	URL_STYLE_HTML   = "html"   // pages are written to "build/blog/post.html"
+	URL_STYLE_PRETTY = "pretty" // pages are written to "build/blog/post/index.html", and linked as "/blog/post/"
 )
 
 // GLOBAL VARS
 var (
-	WORKING_DIR string
-	SOURCE_DIR  string
-	ADDON_DIR   string
+	WORKING_DIR  string
+	SOURCE_DIR   string
+	ADDON_DIR    string
+	PARTIALS_DIR string
+	DATA_DIR     string
 )
 
 //// HELPER FUNCTION
@@ -89,82 +241,385 @@ func checkerr(err error) {
 	}
 }
 
+// calls fn once for every index from 0 to n-1, running at most 'jobs' calls at the same time.
+// if jobs is smaller than 1, the number of CPUs is used. Returns once all calls have finished.
+// a panic in one of the calls is raised again in the calling goroutine, after all other calls finished.
+func runParallel(jobs int, n int, fn func(i int)) {
+	if jobs < 1 {
+		jobs = runtime.NumCPU()
+	}
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	var panicValue interface{}
+
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							panicOnce.Do(func() { panicValue = r })
+						}
+					}()
+					fn(i)
+				}()
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	if panicValue != nil {
+		panic(panicValue)
+	}
+}
+
 func printUsage() {
 	fmt.Println("Usage:")
 	fmt.Println("silvera init   -  Initialize a new silvera workspace")
 	fmt.Println("silvera build  -  Build the files from ./src")
+	fmt.Println("silvera serve  -  Build, serve and rebuild the files from ./src on changes")
+	fmt.Println("silvera graph  -  Export the links between the pages, use --format dot|json")
+	fmt.Println("silvera check  -  Check the links in the build directory")
 }
 
 func getFirstHeadingFromHtml(html_content string) string {
 	// create a new goquery document. Doing this on a pure string doesn't work, have to use a reader.
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(html_content)))
-	checkerr(err)
+	if err != nil {
+		return "" // without a parseable document, there is no title to be found
+	}
 
 	heading := doc.Find("h1:first-of-type").Text() // search for the first h1 element
 
 	return heading
 }
 
-// reads a config file from the given path, using the parent_conf as a base
-func readConfigFile(file_path string, parent_conf Config) Config {
+// normalizes the values produced by yaml.Unmarshal. Nested yaml maps are decoded as
+// map[interface{}]interface{}, which can't be handled by many other packages (like encoding/json),
+// so they are converted to map[string]interface{} recursively.
+func normalizeYamlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprint(key)] = normalizeYamlValue(val)
+		}
+		return m
+	case map[string]interface{}:
+		for key, val := range v {
+			v[key] = normalizeYamlValue(val)
+		}
+		return v
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalizeYamlValue(val)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// matches a line starting a yaml mapping, like "title: Post" or "tags:".
+var yamlKeyLine = regexp.MustCompile(`^[^\s#:\-*>][^:]*:(\s|$)`)
+
+// reports whether a block between '---' lines was meant as front matter, even if it isn't valid yaml.
+// that is the case if its first line (apart from blank lines and comments) starts a yaml mapping.
+func looksLikeFrontMatter(block []byte) bool {
+	for _, line := range strings.Split(string(block), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		return yamlKeyLine.MatchString(trimmed)
+	}
+	return false
+}
+
+// splits a leading yaml 'front matter' block off the given markdown source.
+// the block has to start on the very first line with '---' and is closed by another '---' (or '...') line.
+// returns the parsed front matter and the remaining markdown. If there is no front matter, an empty map
+// and the unchanged markdown are returned. This is also the case if the block is no yaml mapping, as
+// '---' lines are valid Markdown as well: "---\nSome intro\n---" is a thematic break followed by a heading.
+func splitFrontMatter(md_bytes []byte) (map[string]interface{}, []byte, error) {
+	params := make(map[string]interface{})
+
+	// the first line has to be the opening delimiter, otherwise there is no front matter
+	first_line_end := bytes.IndexByte(md_bytes, '\n')
+	if first_line_end == -1 || strings.TrimRight(string(md_bytes[:first_line_end]), " \t\r") != "---" {
+		return params, md_bytes, nil
+	}
+
+	// search line by line for the closing delimiter
+	offset := first_line_end + 1
+	for offset < len(md_bytes) {
+		line_end := bytes.IndexByte(md_bytes[offset:], '\n')
+		var line string
+		var next int
+		if line_end == -1 {
+			line = string(md_bytes[offset:])
+			next = len(md_bytes)
+		} else {
+			line = string(md_bytes[offset : offset+line_end])
+			next = offset + line_end + 1
+		}
+
+		if trimmed := strings.TrimRight(line, " \t\r"); trimmed == "---" || trimmed == "..." {
+			block := md_bytes[first_line_end+1 : offset]
+			if len(bytes.TrimSpace(block)) > 0 {
+				var doc interface{}
+				err := yaml.Unmarshal(block, &doc)
+				if err != nil && !looksLikeFrontMatter(block) {
+					return params, md_bytes, nil // like a list, "* item"
+				}
+				if _, ok := doc.(map[interface{}]interface{}); err == nil && !ok {
+					return params, md_bytes, nil // like a setext heading, "Some intro"
+				}
+			}
+			err := yaml.Unmarshal(block, &params)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid front matter: %w", err)
+			}
+			if params == nil { // an empty block unmarshals to a nil map
+				params = make(map[string]interface{})
+			}
+			normalizeYamlValue(params)
+			return params, md_bytes[next:], nil
+		}
+		offset = next
+	}
+
+	// no closing delimiter was found, so this is not a front matter block after all.
+	return params, md_bytes, nil
+}
+
+// tries to interpret a front matter value as a date.
+// returns the zero time if the value is missing or has an unknown format.
+func parseDate(value interface{}) time.Time {
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case string:
+		layouts := []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+		for _, layout := range layouts {
+			if date, err := time.Parse(layout, v); err == nil {
+				return date
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// reads a config file from the given path, using the parent_conf as a base.
+// see mergeConfig for how the values of the file are combined with the parent values.
+func readConfigFile(file_path string, parent_conf Config) (Config, error) {
 	f, err := ioutil.ReadFile(file_path)
-	checkerr(err)
+	if err != nil {
+		return parent_conf, err
+	}
 
-	var conf Config = parent_conf // initialize the new config with its parent. new values will overwrite the old ones.
-	err = yaml.Unmarshal(f, &conf)
-	checkerr(err)
+	// read the file into a map first, so we know which keys are actually set in the file.
+	var overrides map[string]interface{}
+	err = yaml.Unmarshal(f, &overrides)
+	if err != nil {
+		return parent_conf, fmt.Errorf("invalid config: %w", err)
+	}
+
+	conf, err := mergeConfig(parent_conf, overrides)
+	if err != nil {
+		return parent_conf, fmt.Errorf("invalid config: %w", err)
+	}
 
 	fmt.Printf("Read config file at %s\n", file_path)
 
-	return conf
+	return conf, nil
+}
+
+// combines a parent config with the values read from a config file:
+//   - keys that are set in the file override the parent value.
+//   - keys that are missing from the file, or are left empty (like 'extensions:'), inherit the parent value.
+//   - nested sections (like 'extensions') are merged key by key, following the same rules.
+//   - lists can be extended or shortened, instead of being replaced, using the keys KEY_append
+//     and KEY_remove. For example, 'addons_append: [my-addon]' enables one more addon.
+func mergeConfig(parent_conf Config, overrides map[string]interface{}) (Config, error) {
+	// turn the parent into a map as well, so both can be merged key by key
+	parent_yaml, err := yaml.Marshal(&parent_conf)
+	if err != nil {
+		return parent_conf, err
+	}
+	var base map[string]interface{}
+	if err := yaml.Unmarshal(parent_yaml, &base); err != nil {
+		return parent_conf, err
+	}
+	normalizeYamlValue(base)
+	normalizeYamlValue(overrides)
+
+	merged, err := yaml.Marshal(mergeConfigMaps(base, overrides))
+	if err != nil {
+		return parent_conf, err
+	}
+	var conf Config
+	err = yaml.Unmarshal(merged, &conf) // this fails if a value has the wrong type, like 'tables: maybe'
+	return conf, err
+}
+
+// merges the overrides into the base map, as described for mergeConfig. The base map is modified.
+func mergeConfigMaps(base map[string]interface{}, overrides map[string]interface{}) map[string]interface{} {
+	// first set the plain values, so that e.g. 'addons' and 'addons_append' can be used together
+	for key, value := range overrides {
+		if value == nil || strings.HasSuffix(key, "_append") || strings.HasSuffix(key, "_remove") {
+			continue
+		}
+		sub_overrides, ok_overrides := value.(map[string]interface{})
+		sub_base, ok_base := base[key].(map[string]interface{})
+		if ok_overrides && ok_base {
+			base[key] = mergeConfigMaps(sub_base, sub_overrides)
+		} else {
+			base[key] = value
+		}
+	}
+
+	// then extend or shorten the lists
+	for key, value := range overrides {
+		if value == nil {
+			continue
+		}
+		items, ok := value.([]interface{})
+		if !ok { // a single item doesn't have to be wrapped in a list
+			items = []interface{}{value}
+		}
+		if list_key := strings.TrimSuffix(key, "_append"); list_key != key {
+			list, _ := base[list_key].([]interface{})
+			for _, item := range items {
+				if !listContains(list, item) {
+					list = append(list, item)
+				}
+			}
+			base[list_key] = list
+		} else if list_key := strings.TrimSuffix(key, "_remove"); list_key != key {
+			list, _ := base[list_key].([]interface{})
+			var kept []interface{}
+			for _, item := range list {
+				if !listContains(items, item) {
+					kept = append(kept, item)
+				}
+			}
+			base[list_key] = kept
+		}
+	}
+	return base
+}
+
+// reports whether the list contains the given item.
+func listContains(list []interface{}, item interface{}) bool {
+	for _, other := range list {
+		if reflect.DeepEqual(other, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// reads the local config files at the given paths, mapped by the directory they apply to.
+// each config uses the effective config of its nearest ancestor directory as parent (or the global config
+// if there is none), so the configs are read from the least to the most specific.
+// a config that can't be read is reported, and its directory uses the parent config instead.
+func readLocalConfigs(confPaths map[string]string, global Config, report *errorReport) map[string]Config {
+	dirs := make([]string, 0, len(confPaths))
+	for dir := range confPaths {
+		dirs = append(dirs, dir)
+	}
+	// a parent directory has fewer path elements than its children, so it is always read first.
+	sort.Slice(dirs, func(i, j int) bool {
+		depth_i, depth_j := strings.Count(dirs[i], string(filepath.Separator)), strings.Count(dirs[j], string(filepath.Separator))
+		if depth_i != depth_j {
+			return depth_i < depth_j
+		}
+		return dirs[i] < dirs[j]
+	})
+
+	localConfigs := make(map[string]Config)
+	for _, dir := range dirs {
+		parent := global
+		if dir != SOURCE_DIR {
+			if c := getMostSpecificConfig(localConfigs, filepath.Dir(dir)); c != nil {
+				parent = *c
+			}
+		}
+		conf, err := readConfigFile(confPaths[dir], parent)
+		if err != nil {
+			report.add(confPaths[dir], err)
+			continue
+		}
+		localConfigs[dir] = conf
+	}
+	return localConfigs
 }
 
 // given a file path, will return the config most closely matching that path.
 // if no local config exists, return nil.
 func getMostSpecificConfig(confMap map[string]Config, file_path string) *Config {
 	fileInfo, err := os.Stat(file_path)
-	checkerr(err)
 
 	// if the given path points to a file, remove the file from the path and use just the dir path
 	var dir_path string
-	if fileInfo.IsDir() {
+	if err == nil && fileInfo.IsDir() {
 		dir_path = filepath.Clean(file_path)
 	} else {
 		dir_path = filepath.Dir(file_path)
 	}
 
-	for dir_path != SOURCE_DIR { // as long as the path is more specific than the src root, keep searching.
+	for { // search from the most specific path up to the src root
 		if conf, ok := confMap[dir_path]; ok {
 			fmt.Println("Using local conf for", dir_path)
 			return &conf
-		} else {
-			dir_path = filepath.Clean(strings.TrimSuffix(dir_path, filepath.Base(dir_path))) // shorten the path by its last step, making it less specific
 		}
+		parent := filepath.Dir(dir_path) // shorten the path by its last step, making it less specific
+		if dir_path == SOURCE_DIR || parent == dir_path {
+			return nil
+		}
+		dir_path = parent
 	}
-	return nil
 }
 
 // this contains the logic for executing all the hooks, since they behave largely the same.
-func runHookForPrefix(conf Config, prefix string, args []string) {
+// the first addon that fails stops the hook, and its error is returned.
+func runHookForPrefix(conf Config, prefix string, args []string) error {
 	for _, addon_name := range conf.Addons {
 		addon_dir := filepath.Join(ADDON_DIR, addon_name)
 		files, err := ioutil.ReadDir(addon_dir)
-		checkerr(err)
+		if err != nil {
+			return fmt.Errorf("addon %s is not installed: %w", addon_name, err)
+		}
 		for _, file := range files {
 			if !file.IsDir() && strings.HasPrefix(file.Name(), prefix) {
 				cmd := filepath.Join(filepath.Join(ADDON_DIR, addon_name), file.Name())
 				var out []byte
 				ext := filepath.Ext(file.Name())
 				if ext == ".py" {
-					args = append([]string{cmd}, args...) // when using an interpreter like python, the program is an arg to the interpreter
-					out, err = exec.Command("python", args...).Output()
+					cmd_args := append([]string{cmd}, args...) // when using an interpreter like python, the program is an arg to the interpreter
+					out, err = exec.Command("python", cmd_args...).Output()
 				} else if ext == ".sh" {
-					args = append([]string{cmd}, args...)
-					out, err = exec.Command("bash", args...).Output()
+					cmd_args := append([]string{cmd}, args...)
+					out, err = exec.Command("bash", cmd_args...).Output()
 				} else {
 					out, err = exec.Command(cmd, args...).Output()
 				}
-				checkerr(err)
+				if err != nil {
+					// if the addon wrote something to stderr, that is most likely the actual cause
+					if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+						return fmt.Errorf("addon %s failed: %w: %s", cmd, err, strings.TrimSpace(string(exitErr.Stderr)))
+					}
+					return fmt.Errorf("addon %s failed: %w", cmd, err)
+				}
 				if PRINT_HOOK {
 					fmt.Printf("Ran addon: %s\n", cmd)
 					fmt.Printf("ADDON_OUT:\n %s\n", out)
@@ -172,7 +627,109 @@ func runHookForPrefix(conf Config, prefix string, args []string) {
 			}
 		}
 	}
+	return nil
+}
+
+//// ERROR REPORTING
+// errors that concern a single file don't stop the build. Instead, they are collected in an
+// errorReport, and once the build is done, returned together as a single error.
+// -----------------------------------------------------------------------------------
+
+// this error is returned by the walk through the source directory, if the build was stopped early.
+var errBuildStopped = errors.New("build stopped")
+
+// this struct describes an error that occurred while building a single file.
+type BuildError struct {
+	Path   string // the path of the file that caused the error
+	Line   int    // the line the error occurred on, or 0 if it is unknown
+	Column int    // the column the error occurred on, or 0 if it is unknown
+	Err    error  // the actual cause
+}
+
+func (e *BuildError) Error() string {
+	if e.Line > 0 && e.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %v", relativeToWorkspace(e.Path), e.Line, e.Column, e.Err)
+	}
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %v", relativeToWorkspace(e.Path), e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", relativeToWorkspace(e.Path), e.Err)
+}
+
+// returns the path relative to the workspace if it is inside of it, to keep messages short.
+func relativeToWorkspace(path string) string {
+	if rel, err := filepath.Rel(WORKING_DIR, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
 
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// this error is returned by a build with at least one failed file. It lists all the errors.
+type BuildErrors []*BuildError
+
+func (errs BuildErrors) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "build failed with %d error(s):", len(errs))
+	for _, err := range errs {
+		sb.WriteString("\n  " + err.Error())
+	}
+	return sb.String()
+}
+
+// this struct collects the errors of a single build. It may be used from multiple goroutines at once.
+// problems that don't make the build fail are collected as warnings.
+type errorReport struct {
+	mutex    sync.Mutex
+	errors   BuildErrors
+	warnings []*BuildError
+	failFast bool // if set, the build should stop after the first error
+}
+
+// records an error for the file at path, and prints it right away.
+func (report *errorReport) add(path string, err error) {
+	report.addLine(path, 0, err)
+}
+
+// records an error for the given line of the file at path, and prints it right away.
+func (report *errorReport) addLine(path string, line int, err error) {
+	build_err := &BuildError{Path: path, Line: line, Err: err}
+	fmt.Println("Err:", build_err)
+
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.errors = append(report.errors, build_err)
+}
+
+// records a warning for the file at path, and prints it right away.
+func (report *errorReport) warn(path string, message string) {
+	warning := &BuildError{Path: path, Err: errors.New(message)}
+	fmt.Println("Warning:", warning)
+
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.warnings = append(report.warnings, warning)
+}
+
+// reports whether the build should stop, because an error occurred in fail-fast mode.
+func (report *errorReport) stopped() bool {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	return report.failFast && len(report.errors) > 0
+}
+
+// returns all the collected errors, sorted by path, or nil if there were none.
+func (report *errorReport) err() error {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	if len(report.errors) == 0 {
+		return nil
+	}
+	sort.SliceStable(report.errors, func(i, j int) bool { return report.errors[i].Path < report.errors[j].Path })
+	return report.errors
 }
 
 //// FLAG BUILDERS
@@ -217,6 +774,9 @@ func buildExtensionList(conf Config) []gm.Extender {
 	if e.TableOfContents {
 		extList = append(extList, &gmtoc.Extender{})
 	}
+	if e.SyntaxHighlighting.Enabled {
+		extList = append(extList, highlightExtension(e.SyntaxHighlighting))
+	}
 	return extList
 }
 
@@ -263,28 +823,28 @@ func buildRendererOptList(conf Config) []gmr.Option {
 
 // this hook is part of the 'build' command.
 // it is called right at the beginning, before any processing happens.
-func hookPre(conf Config) {
-	runHookForPrefix(conf, "prh__", []string{})
+func hookPre(conf Config) error {
+	return runHookForPrefix(conf, "prh__", []string{})
 }
 
 // this hook is part of the 'build' command.
 // it is called right before a Markdown file is read for processing.
 // this makes it useful for modifying the source (.md) file ahead of processing.
-func hookPreFile(conf Config, filepath string) {
-	runHookForPrefix(conf, "prf__", []string{filepath})
+func hookPreFile(conf Config, filepath string) error {
+	return runHookForPrefix(conf, "prf__", []string{filepath})
 }
 
 // this hook is part of the 'build' command.
 // it is called right before a Markdown file is read for processing.
 // this makes it useful for modifying the build (.html) file after of processing.
-func hookPostFile(conf Config, filepath string) {
-	runHookForPrefix(conf, "pof__", []string{filepath})
+func hookPostFile(conf Config, filepath string) error {
+	return runHookForPrefix(conf, "pof__", []string{filepath})
 }
 
 // this hook is part of the 'build' command.
 // it is called right at the end, after all the processing has finished.
-func hookPost(conf Config) {
-	runHookForPrefix(conf, "poh__", []string{})
+func hookPost(conf Config) error {
+	return runHookForPrefix(conf, "poh__", []string{})
 }
 
 //// COMMANDS
@@ -293,24 +853,29 @@ func hookPost(conf Config) {
 // a command string like "build" to a function.
 // -------------------------------------------------------------------------
 
-var commands map[string]func()
+// each command function receives the remaining command line arguments (after the command name).
+// if a command returns an error, silvera exits with a non-zero exit code.
+var commands map[string]func(args []string) error
 
 // here, the string keys are associated to the corresponding functions.
 func registerCommands() {
 	commands["init"] = commandInit
 	commands["build"] = commandBuild
+	commands["serve"] = commandServe
+	commands["graph"] = commandGraph
+	commands["check"] = commandCheck
 }
 
 // the 'init' command is used to transform an existing directory into a workspace.
 // a 'scr' directory and a config file are created, initializing the latter with
 // default values defined here.
-func commandInit() {
+func commandInit(args []string) error {
 	confpath := filepath.Join(WORKING_DIR, "silvera.conf")
 
 	// check if there is already a config file. if so, assume that this is already a workspace and return.
 	if _, err := os.Stat(confpath); err == nil {
 		fmt.Println("This directory already appears to be a silvera workspace. Nothing changed.")
-		return
+		return nil
 	} else {
 		// create default config file
 		defaultConfig := Config{
@@ -327,6 +892,13 @@ func commandInit() {
 				Wikilink:        true,
 				Mathjax:         false,
 				TableOfContents: false,
+				SyntaxHighlighting: HighlightConf{
+					Enabled:     false,
+					Style:       "github",
+					LineNumbers: false,
+					Mode:        "inline",
+					Stylesheet:  "syntax.css",
+				},
 			},
 			ParserOptions: ParserOpts{
 				WithAttribute:     true,
@@ -337,32 +909,69 @@ func commandInit() {
 				WithXHTML:     true,
 				WithUnsafe:    false,
 			},
-			Addons: []string{},
+			URLStyle: URL_STYLE_HTML,
+			Addons:   []string{},
+			Taxonomies: []TaxonomyConf{
+				{Name: "tags"},
+				{Name: "categories"},
+			},
+			TOC: TOCConf{
+				MinDepth: 2,
+				MaxDepth: 3,
+			},
+			Pagination: PaginationConf{
+				PageSize: DEFAULT_PAGE_SIZE,
+			},
 		}
 
 		// transform the struct to yaml data and write it to a file.
 		yamlData, err := yaml.Marshal(&defaultConfig)
-		checkerr(err)
+		if err != nil {
+			return err
+		}
 		err = os.WriteFile(confpath, []byte(yamlData), 0644)
-		checkerr(err)
+		if err != nil {
+			return err
+		}
 
 		// create a default template.html file
 		err = os.WriteFile(defaultConfig.Templatedir, []byte("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<title>{{.Title}}</title>\n</head>\n<body>\n{{.Body}}\n</body>\n</html>\n"), 0644)
-		checkerr(err)
+		if err != nil {
+			return err
+		}
 
 		// create a src directory
 		err = os.MkdirAll(SOURCE_DIR, 0755)
-		checkerr(err)
+		if err != nil {
+			return err
+		}
 
 		// create a build directory
 		err = os.MkdirAll(defaultConfig.Outdir, 0755)
-		checkerr(err)
+		if err != nil {
+			return err
+		}
 
 		// create an addon directory
 		err = os.MkdirAll(ADDON_DIR, 0755)
-		checkerr(err)
+		if err != nil {
+			return err
+		}
+
+		// create a directory for partial templates
+		err = os.MkdirAll(PARTIALS_DIR, 0755)
+		if err != nil {
+			return err
+		}
+
+		// create a directory for data files
+		err = os.MkdirAll(DATA_DIR, 0755)
+		if err != nil {
+			return err
+		}
 
 		fmt.Printf("Initialized new silvera workspace at %s\n", WORKING_DIR)
+		return nil
 	}
 }
 
@@ -370,21 +979,63 @@ func commandInit() {
 // out of them. Inbetween the steps of this pipeline, various hooks are run (see above for hook definitions).
 // Only '.md' files are actually processed and turned into '.html' files, all other files and directories are
 // simply copied over.
-func commandBuild() {
-	config := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{}) // read a new config with an empty parent. This is the global config.
-	os.MkdirAll(config.Outdir, 0755)                                               // if necessary, create the build directory as given in the config file.
+func commandBuild(args []string) error {
+	var opts BuildOptions
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	flags.BoolVar(&opts.Force, "force", false, "ignore the build cache and rebuild every file")
+	flags.IntVar(&opts.Jobs, "j", runtime.NumCPU(), "the number of files to build in parallel")
+	flags.BoolVar(&opts.FailFast, "fail-fast", false, "stop the build at the first error, instead of building all other files")
+	addPublishFlags(flags, &opts)
+	flags.Parse(args)
+
+	return buildSite(opts)
+}
 
-	hookPre(config) // run the pre-processing hook
+// this struct holds the options that modify how the site is built.
+type BuildOptions struct {
+	Force    bool // ignore the build cache, and rebuild every file
+	Jobs     int  // the number of files that are built at the same time. if < 1, the number of CPUs is used.
+	FailFast bool // stop at the first error, instead of building as much as possible
+	Drafts   bool // include pages with 'draft: true' in their front matter
+	Future   bool // include pages that are scheduled to be published in the future
+	Expired  bool // include pages whose 'expiry_date' has passed
+}
 
-	localConfigs := make(map[string]Config) // this map holds configuration structs based on directory names
+// this struct describes a single file that was discovered in the source directory.
+type buildJob struct {
+	srcPath string // the absolute path of the source file
+	relpath string // the path relative to the source directory
+	outpath string // the path in the build directory the file would be copied to
+	conf    Config // the configuration that applies to this file
+	isDir   bool   // whether this is a directory, which only has to be created in the build directory
+}
 
-	// recursively walk through the source directory
+// walks through the source directory, and returns a job for every file and directory that has to be built,
+// in the order the walk visited them. Each job carries the local config that applies to it.
+// local configs and other hidden files are not part of the result.
+func collectJobs(config Config, report *errorReport) []buildJob {
+	confPaths := make(map[string]string) // this map holds the paths of the local config files, by the directory they apply to
+	var discovered []string              // this slice holds all the files and directories that have to be built
+	isDir := make(map[string]bool)       // this map tells which of the discovered paths are directories
+
+	// recursively walk through the source directory, discovering the work that has to be done.
 	filepath.Walk(SOURCE_DIR, func(path string, info os.FileInfo, err error) error {
+		// with --fail-fast, a single error ends the walk.
+		if report.stopped() {
+			return errBuildStopped
+		}
+
+		// don't stop on errors, just report them. We don't want a single file error to prevent building.
+		if err != nil {
+			report.add(path, err)
+			return nil
+		}
+
 		// if a HIDDEN_DIR local config dir is encountered, see if it has a configuration file
 		if filepath.Base(path) == HIDDEN_DIR && info.IsDir() {
 			conf_path := filepath.Join(path, "silvera.conf") // the path where the config should be located
-			if _, err := os.Stat(conf_path); err == nil {    // if the config exists and is readable...
-				localConfigs[filepath.Clean(strings.TrimSuffix(path, HIDDEN_DIR))] = readConfigFile(conf_path, config) // ...then load it into the localConfigs map
+			if _, err := os.Stat(conf_path); err == nil {    // if the config exists and is readable, remember it for later
+				confPaths[filepath.Clean(strings.TrimSuffix(path, HIDDEN_DIR))] = conf_path
 			}
 			return filepath.SkipDir
 		}
@@ -398,12 +1049,17 @@ func commandBuild() {
 			}
 		}
 
-		// don't stop on errors, just output them. We don't want a single file error to prevent building.
-		if err != nil {
-			fmt.Println("Err:", err)
-			return nil
-		}
+		discovered = append(discovered, path)
+		isDir[path] = info.IsDir()
+		return nil
+	})
 
+	// only once all local configs are known, they can be read in the right order.
+	// this way, each of them inherits from the config of its nearest ancestor.
+	localConfigs := readLocalConfigs(confPaths, config, report) // this map holds configuration structs based on directory names
+
+	var jobs []buildJob
+	for _, path := range discovered {
 		// determine a configuration used for this path
 		var localConf Config
 		if c := getMostSpecificConfig(localConfigs, path); c != nil { // if there is a local config, use that one
@@ -414,52 +1070,194 @@ func commandBuild() {
 
 		relpath := strings.TrimPrefix(path, SOURCE_DIR)     // get the relative path in the source directory.
 		outpath := filepath.Join(localConf.Outdir, relpath) // get the relative path in the build directory.
+		jobs = append(jobs, buildJob{srcPath: path, relpath: relpath, outpath: outpath, conf: localConf, isDir: isDir[path]})
+	}
+	return jobs
+}
 
-		// if a directory is encountered, just copy/mirror it over to the build dir.
-		if info.IsDir() {
-			os.Mkdir(outpath, 0755)
-			return nil
-			// if a '.md' file is encountered, begin processing it to '.html'
-		} else if strings.HasSuffix(relpath, ".md") {
-			outpath := strings.TrimSuffix(outpath, ".md")
-			outpath = outpath + ".html"
+// this function runs the actual build pipeline, as described for the 'build' command above.
+// it is shared by all commands that need to build the site.
+// errors concerning single files don't stop the build (unless opts.FailFast is set), but are collected
+// and returned together once the build is done.
+func buildSite(opts BuildOptions) error {
+	report := &errorReport{failFast: opts.FailFast} // this collects the errors of all files
+
+	conf_path := filepath.Join(WORKING_DIR, "silvera.conf")
+	config, err := readConfigFile(conf_path, Config{}) // read a new config with an empty parent. This is the global config.
+	if err != nil {
+		return &BuildError{Path: conf_path, Err: err} // without the global config, nothing can be built
+	}
+	if config.URLStyle != "" && config.URLStyle != URL_STYLE_HTML && config.URLStyle != URL_STYLE_PRETTY {
+		return &BuildError{Path: conf_path, Err: fmt.Errorf("unknown url_style %q, expected %q or %q", config.URLStyle, URL_STYLE_HTML, URL_STYLE_PRETTY)}
+	}
+	// if necessary, create the build directory as given in the config file.
+	if err := os.MkdirAll(config.Outdir, 0755); err != nil {
+		return &BuildError{Path: config.Outdir, Err: err}
+	}
+
+	// run the pre-processing hook
+	if err := hookPre(config); err != nil {
+		return &BuildError{Path: ADDON_DIR, Err: err}
+	}
+
+	cache := loadBuildCache(opts.Force) // the cache allows skipping files whose inputs didn't change
+
+	// the partials are shared by all templates, so if one of them is broken, no page can be built
+	templates, err := loadTemplates(PARTIALS_DIR, config)
+	if err != nil {
+		return err
+	}
+	// the same goes for the data files
+	data, err := loadData(DATA_DIR)
+	if err != nil {
+		return err
+	}
+
+	var jobs []buildJob // this slice collects all the files that have to be built
+	for _, job := range collectJobs(config, report) {
+		// if a directory is encountered, just copy/mirror it over to the build dir right away.
+		// the walk visits parents before their children, so the parent directory always exists already.
+		if job.isDir {
+			if err := os.Mkdir(job.outpath, 0755); err != nil && !os.IsExist(err) {
+				report.add(job.srcPath, err)
+			}
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	// drafts, scheduled and expired pages are left out entirely (see publish.go)
+	jobs, unpublished := filterUnpublished(jobs, opts)
+	removeUnpublished(unpublished, report)
+	// no two files may be written to the same place, which can happen with pretty urls (see links.go)
+	jobs = dropCollisions(jobs, report)
+
+	// now that all pages are known, links between them can be resolved
+	index := newPageIndex(jobs)
+
+	// FIRST PASS: Markdown files are converted and collected in the site struct, all other files are copied over.
+	// each file is handled by one of the workers, so the order in which files are finished is not fixed.
+	loaded := make([]*Page, len(jobs)) // each worker only writes to the index of its own job
+	runParallel(opts.Jobs, len(jobs), func(i int) {
+		job := jobs[i]
+		if report.stopped() {
+			return
+		}
+		// if a '.md' file is encountered, begin processing it to '.html'
+		if strings.HasSuffix(job.relpath, ".md") {
 			// run pre-file-processing hook
-			hookPreFile(localConf, path)
-			// process the file to html
-			html_bytes, err := renderMdToHtml(path, localConf)
+			if err := hookPreFile(job.conf, job.srcPath); err != nil {
+				report.add(job.srcPath, err)
+				return
+			}
+			// process the file to html. The page is only embedded in its template once all pages are known.
+			page, err := loadPage(job.srcPath, job.relpath, job.outpath, job.conf, cache, index)
 			if err != nil {
-				return err
+				report.add(job.srcPath, err)
+				return
 			}
+			for _, warning := range page.warnings {
+				report.warn(job.srcPath, warning)
+			}
+			loaded[i] = page
+			// if some file is encountered that is not a '.md' file, copy it over to the build
+			// directory with no changes made.
+		} else if err := copyFile(job, cache); err != nil {
+			report.add(job.srcPath, err)
+		}
+	})
+
+	site := Site{} // this struct holds all the pages, once the first pass is done
+	for _, page := range loaded {
+		if page != nil {
+			site.Pages = append(site.Pages, page)
+		}
+	}
 
-			// embed the processed html in the template file
-			full_html_bytes := embedHtmlInTemplate(html_bytes, relpath, localConf)
+	// SECOND PASS: now that all pages are known, embed each of them in its template.
+	sort.Slice(site.Pages, func(i, j int) bool { return site.Pages[i].relpath < site.Pages[j].relpath })
+	buildLinkGraph(&site)
+	site.Taxonomies = buildTaxonomies(config, &site)
+	buildPaginators(&site)
+	site_hash := hashParts([]byte(siteHash(&site)), []byte(hashDir(DATA_DIR))) // the data files are available to every page, just like the site
+	runParallel(opts.Jobs, len(site.Pages), func(i int) {
+		page := site.Pages[i]
+		if report.stopped() {
+			return
+		}
 
-			fmt.Println("built:", relpath, "->", outpath)
+		// skip the page if neither it, its template nor the site changed since the last build
+		output_hash := cache.outputHash(page, site_hash, templates.hash)
+		if cache.isFresh(page.relpath, page.inputHash, output_hash, page.outPath) && paginatorsExist(page.paginators) {
+			cache.record(page.relpath, page.inputHash, output_hash)
+			return
+		}
 
-			// write the html byte slice to the file-path determined above, ending in '.md'.
-			err = ioutil.WriteFile(outpath, full_html_bytes, 0644)
-			if err == nil {
-				// if all went well so far, run the post-file-processing hook
-				hookPostFile(localConf, outpath)
+		// listing pages are written once for every page of the listing, all other pages only once
+		paginators := page.paginators
+		if len(paginators) == 0 {
+			paginators = []*Paginator{nil}
+		}
+		for _, paginator := range paginators {
+			out_path := page.outPath
+			if paginator != nil {
+				out_path = paginator.outPath
 			}
-			return err
-			// if some file is encountered that is neither a dir, nor a '.md' file, copy it over to the build
-			// directory with no changes made.
-		} else {
-			srcfile, err := ioutil.ReadFile(path) // read the input file
+
+			full_html_bytes, err := embedHtmlInTemplate(page, &site, data, templates, paginator)
 			if err != nil {
-				return err
+				report.add(page.srcPath, err)
+				return
 			}
-			err = ioutil.WriteFile(outpath, srcfile, 0644) // write it back to the build dir
+
+			fmt.Println("built:", page.relpath, "->", out_path)
+
+			// write the html byte slice to the file-path determined in the first pass, ending in '.html'.
+			// further pages of a listing are placed in new directories, which have to be created first.
+			err = os.MkdirAll(filepath.Dir(out_path), 0755)
 			if err == nil {
-				fmt.Println("clone:", relpath, "->", outpath)
+				err = ioutil.WriteFile(out_path, full_html_bytes, 0644)
+			}
+			if err != nil {
+				report.add(page.srcPath, err)
+				return
+			}
+			// if all went well so far, run the post-file-processing hook
+			if err := hookPostFile(page.conf, out_path); err != nil {
+				report.add(page.srcPath, err)
+				return
 			}
-			return err
 		}
+		cache.record(page.relpath, page.inputHash, output_hash)
 	})
 
-	// run the post-processing hook
-	hookPost(config)
+	// generate the feeds configured in the global config
+	if !report.stopped() {
+		writeFeeds(config, &site, report)
+		writeSitemap(config, &site, report)
+		writeHighlightStylesheet(config, report)
+		writeTaxonomies(config, &site, data, templates, report)
+	}
+
+	if err := cache.save(); err != nil {
+		fmt.Println("Warning: could not save the build cache:", err) // the build itself is fine, so this is no error
+	}
+
+	// run the post-processing hook, unless the build was stopped
+	if !report.stopped() {
+		if err := hookPost(config); err != nil {
+			report.add(ADDON_DIR, err)
+		}
+	}
+
+	// once everything is written, check the links of the whole build directory if enabled
+	if config.CheckLinks && !report.stopped() {
+		checkSiteLinks(config, jobs, report)
+	}
+
+	if len(report.warnings) > 0 {
+		fmt.Printf("Build finished with %d warning(s).\n", len(report.warnings))
+	}
+	return report.err()
 }
 
 //// PROCESSING FUNCTIONS
@@ -467,49 +1265,197 @@ func commandBuild() {
 // and then return the converted file as a byte array.
 // ---------------------------------------------------------------------------------------------------
 
+// this function copies a file that is not processed in any way over to the build directory.
+// if the file didn't change since the last build, and the copy still exists, nothing is done.
+func copyFile(job buildJob, cache *buildCache) error {
+	srcfile, err := ioutil.ReadFile(job.srcPath) // read the input file
+	if err != nil {
+		return err
+	}
+	input_hash := cache.inputHash(srcfile, job.outpath, Config{})
+	if cache.isFresh(job.relpath, input_hash, "", job.outpath) { // skip the copy if the file didn't change
+		cache.record(job.relpath, input_hash, "")
+		return nil
+	}
+	err = ioutil.WriteFile(job.outpath, srcfile, 0644) // write it back to the build dir
+	if err == nil {
+		fmt.Println("clone:", job.relpath, "->", job.outpath)
+		cache.record(job.relpath, input_hash, "")
+	}
+	return err
+}
+
+// this struct holds everything the Markdown renderer produces for a single file.
+type renderedMarkdown struct {
+	html     []byte                 // the generated html
+	params   map[string]interface{} // the front matter of the file
+	warnings []string               // problems that don't prevent rendering, like links to missing pages
+}
+
 // this function takes in a path to a '.md' file, and using the goldmark (gm) package, transforms it to html,
 // using the configuration obtained from the users config file to adjust what internal extensions and options to use.
-func renderMdToHtml(filepath string, config Config) ([]byte, error) {
-	md_bytes, err := ioutil.ReadFile(filepath)
+// a leading yaml front matter block is stripped from the file before processing, and returned as a map.
+// links to other '.md' files and wikilinks are resolved to the built pages, using the page index (which may be nil).
+func renderMdToHtml(filepath string, config Config, index *pageIndex) (*renderedMarkdown, error) {
+	file_bytes, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	// split off the front matter, so it doesn't end up in the rendered html
+	params, md_bytes, err := splitFrontMatter(file_bytes)
 	if err != nil {
 		return nil, err
 	}
+	result := &renderedMarkdown{params: params}
 
 	// register all options and exts as per config file to the processor
+	parserOpts := buildParserOptList(config)
+	if index != nil {
+		transformer := &linkTransformer{srcPath: filepath, conf: config, index: index, warnings: &result.warnings}
+		parserOpts = append(parserOpts, gmp.WithASTTransformers(util.Prioritized(transformer, 100)))
+	}
+	parserOpts = append(parserOpts, gmp.WithASTTransformers(util.Prioritized(&summaryTransformer{}, 100)))
 	md := gm.New(
 		gm.WithExtensions(buildExtensionList(config)...),
-		gm.WithParserOptions(buildParserOptList(config)...),
+		gm.WithParserOptions(parserOpts...),
 		gm.WithRendererOptions(buildRendererOptList(config)...),
 	)
 	var buf bytes.Buffer
 	err = md.Convert(md_bytes, &buf) // run the converter on the '.md' data that was read above
-	html_bytes := buf.Bytes()        // retrieve the result as a slice of bytes
-	return html_bytes, err
+	result.html = buf.Bytes()        // retrieve the result as a slice of bytes
+	return result, err
 }
 
-// this function takes in the already processed html as a byte slice, and using golangs html/template
-// library, embeds these contents in the template.
-func embedHtmlInTemplate(html_contents []byte, curr_path string, config Config) []byte {
-	// this struct will hold the data to be embedded into to template
-	type EmbeddableContents struct {
-		Title string
-		Body  string
-		Path  string
+// this function converts the '.md' file at src_path to html and collects everything that is known
+// about it in a Page struct. relpath is the path relative to the source directory, and outpath the
+// path the '.md' file would be copied to in the build directory.
+// if the build cache holds html rendered from the same inputs, that is used instead of rendering again.
+// the cache and the page index may be nil, in which case the file is always rendered, and links are left as they are.
+func loadPage(src_path string, relpath string, outpath string, config Config, cache *buildCache, index *pageIndex) (*Page, error) {
+	var html_bytes []byte
+	var params map[string]interface{}
+	var warnings []string
+	var input_hash string
+
+	file_bytes, err := ioutil.ReadFile(src_path)
+	if err != nil {
+		return nil, err
+	}
+	cached := false
+	if cache != nil {
+		// links depend on which other pages exist, so the page index is part of the inputs
+		index_hash := ""
+		if index != nil {
+			index_hash = index.hash
+		}
+		input_hash = cache.inputHash(file_bytes, outpath, config, index_hash)
+		html_bytes, cached = cache.body(input_hash)
+	}
+
+	if cached {
+		// the front matter is not part of the cached html, so it has to be read again
+		params, _, err = splitFrontMatter(file_bytes)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		rendered, err := renderMdToHtml(src_path, config, index)
+		if err != nil {
+			return nil, err
+		}
+		html_bytes, params, warnings = rendered.html, rendered.params, rendered.warnings
+		// pages with warnings are not cached, so the warnings show up again in the next build
+		if cache != nil && len(warnings) == 0 {
+			cache.storeBody(input_hash, html_bytes)
+		}
+	}
+
+	// the template may be chosen by the page itself
+	template_path, err := pageTemplate(src_path, params, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// a title given in the front matter takes precedence over the auto detected one
+	title, ok := params["title"].(string)
+	if !ok || title == "" {
+		title = getFirstHeadingFromHtml(string(html_bytes))
 	}
 
-	tmpl := template.Must(template.ParseFiles(config.Templatedir)) // read in the template file, panicking on failure
+	page := &Page{
+		Path:      pagePath(relpath, config),
+		URL:       pageURL(relpath, config),
+		Title:     title,
+		Params:    params,
+		Date:      parseDate(params["date"]),
+		Summary:   extractSummary(params, html_bytes, pageURL(relpath, config)),
+		WordCount: countWords(html_bytes),
+		TOC:       buildTOC(html_bytes, config.TOC),
+		relpath:   relpath,
+		srcPath:   src_path,
+		outPath:   pageOutPath(outpath, config),
+		conf:      config,
+		template:  template_path,
+		body:      html_bytes,
+		inputHash: input_hash,
+		warnings:  warnings,
+	}
+	page.ReadingTime = readingTime(page.WordCount)
+	if info, err := os.Stat(src_path); err == nil {
+		page.modTime = info.ModTime()
+	}
+	return page, nil
+}
 
+// this function takes in an already processed page, and using golangs html/template
+// library, embeds its contents in the template. The template is parsed together with the partials, see templates.go.
+// the front matter params are made available to the template as {{.Params.key}},
+// the data about the whole site as {{.Site}}, and the contents of the data files as {{.Data}}.
+// on listing pages, the current page of the listing is given as {{.Paginator}}, for all other pages it is nil.
+func embedHtmlInTemplate(page *Page, site *Site, data map[string]interface{}, templates *templateSet, paginator *Paginator) ([]byte, error) {
 	contents := EmbeddableContents{
-		Title: getFirstHeadingFromHtml(string(html_contents)),
-		Body:  string(html_contents),
-		Path:  curr_path,
+		Title:     page.Title,
+		Body:      template.HTML(page.body), // the body is html generated by silvera, so it must not be escaped
+		Path:      page.Path,
+		URL:       page.URL,
+		Params:    page.Params,
+		Site:      site,
+		Data:      data,
+		OutLinks:  page.outLinks,
+		Backlinks: page.backlinks,
+
+		Summary:     page.Summary,
+		WordCount:   page.WordCount,
+		ReadingTime: page.ReadingTime,
+		TOC:         page.TOC,
+		Paginator:   paginator,
+	}
+	if paginator != nil {
+		contents.URL = paginator.URL // further pages of a listing have urls of their own
+		contents.Body = template.HTML(paginatedBody(page, paginator))
+	}
+	return executeTemplate(page.template, contents, templates)
+}
+
+// embeds the given contents in the template at template_path. This is also used for generated pages, like taxonomies.
+func executeTemplate(template_path string, contents EmbeddableContents, templates *templateSet) ([]byte, error) {
+	// generated pages have no headings, but templates shared with regular pages may still use {{.TOC.HTML}}
+	if contents.TOC == nil {
+		contents.TOC = &TOC{}
+	}
+	tmpl, err := templates.get(template_path) // get the template file, parsed together with the partials
+	if err != nil {
+		return nil, err
 	}
 
 	var buf bytes.Buffer
-	tmpl.Execute(&buf, contents)
+	if err := tmpl.Execute(&buf, contents); err != nil {
+		return nil, err
+	}
 	total_html := buf.Bytes()
 
-	return total_html
+	return total_html, nil
 }
 
 //// MAIN
@@ -518,7 +1464,7 @@ func embedHtmlInTemplate(html_contents []byte, curr_path string, config Config)
 
 // the init function is run directly before 'main()', and is used to initialize some values.
 func init() {
-	commands = map[string]func(){}
+	commands = map[string]func(args []string) error{}
 
 	// get working directory
 	path, err := os.Getwd()
@@ -528,6 +1474,8 @@ func init() {
 	// source path
 	SOURCE_DIR = filepath.Join(WORKING_DIR, "src/")
 	ADDON_DIR = filepath.Join(WORKING_DIR, "addons/")
+	PARTIALS_DIR = filepath.Join(WORKING_DIR, "partials/")
+	DATA_DIR = filepath.Join(WORKING_DIR, "data/")
 }
 
 // the main function is the entrypoint to this program.
@@ -545,7 +1493,10 @@ func main() {
 	// run command if possible
 	cmd := os.Args[1]
 	if cmdFunc, ok := commands[cmd]; ok { // if the command we ask for exists...
-		cmdFunc()
+		if err := cmdFunc(os.Args[2:]); err != nil {
+			fmt.Println("Err:", err)
+			os.Exit(1)
+		}
 	} else { // and if it doesn't exist...
 		fmt.Printf("Unknown command %s\n", cmd)
 		printUsage()
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"gopkg.in/yaml.v2"
@@ -34,10 +35,14 @@ func TestRun(t *testing.T) {
 	path := filepath.Join(TEST_ROOT, "test_env")
 	WORKING_DIR = path
 
+	err := os.MkdirAll(WORKING_DIR, 0755)
+	testerr(err, t)
+
 	old_files, err := filepath.Glob(filepath.Join(WORKING_DIR, "*"))
 	testerr(err, t)
 
-	// delete all old files
+	// delete all old files, including the build cache
+	old_files = append(old_files, filepath.Join(WORKING_DIR, HIDDEN_DIR))
 	for _, f := range old_files {
 		if err := os.RemoveAll(f); err != nil {
 			t.Error(err)
@@ -50,7 +55,9 @@ func TestRun(t *testing.T) {
 	// source path
 	SOURCE_DIR = filepath.Join(WORKING_DIR, "src/")
 	ADDON_DIR = filepath.Join(WORKING_DIR, "addons/")
-	commandInit()
+	PARTIALS_DIR = filepath.Join(WORKING_DIR, "partials/")
+	DATA_DIR = filepath.Join(WORKING_DIR, "data/")
+	testerr(commandInit(nil), t)
 
 	var checklist []string = []string{ // these files must have been created
 		"addons",
@@ -156,10 +163,307 @@ func TestRun(t *testing.T) {
 	err = os.WriteFile(filepath.Join(SOURCE_DIR, "subdir2/subdir2subdir1/index.md"), []byte(test_markdown), 0644)
 	testerr(err, t)
 
-	commandBuild()
+	testerr(commandBuild(nil), t)
 
 	compareToCorrect(defaultConfig.Outdir, filepath.Join(TEST_ROOT, "correct_results"), "subdir1/index.html", t)
 	compareToCorrect(defaultConfig.Outdir, filepath.Join(TEST_ROOT, "correct_results"), "subdir2/index.html", t)
 	compareToCorrect(defaultConfig.Outdir, filepath.Join(TEST_ROOT, "correct_results"), "subdir1/subdir1subdir1/index.html", t)
 	compareToCorrect(defaultConfig.Outdir, filepath.Join(TEST_ROOT, "correct_results"), "subdir2/subdir2subdir1/index.html", t)
 }
+
+func TestFrontMatter(t *testing.T) {
+	md := "---\ntitle: Custom Title\nauthor: someone\ntags:\n  - a\n  - b\n---\n# Heading\nSome text.\n"
+	params, rest, err := splitFrontMatter([]byte(md))
+	testerr(err, t)
+
+	if params["title"] != "Custom Title" || params["author"] != "someone" {
+		t.Errorf("unexpected front matter: %v", params)
+	}
+	if tags, ok := params["tags"].([]interface{}); !ok || len(tags) != 2 {
+		t.Errorf("unexpected tags: %v", params["tags"])
+	}
+	if string(rest) != "# Heading\nSome text.\n" {
+		t.Errorf("front matter not stripped: %q", rest)
+	}
+
+	// files without front matter, with an unclosed block, or with a block that is no yaml mapping are left untouched.
+	// the latter are thematic breaks around Markdown, like a setext heading or a list
+	for _, md := range []string{
+		"# Heading\n---\nfoo\n---\n",
+		"---\nfoo: bar\n# Heading\n",
+		"---\nSome intro\n---\n",
+		"---\n* item\n---\n",
+		"---\n- item\n---\n",
+		"---\n# Heading\n---\n",
+	} {
+		params, rest, err := splitFrontMatter([]byte(md))
+		testerr(err, t)
+		if len(params) != 0 || string(rest) != md {
+			t.Errorf("expected no front matter in %q, got %v", md, params)
+		}
+	}
+
+	// a block that was meant as front matter is still reported if it is invalid
+	if _, _, err := splitFrontMatter([]byte("---\ntitle: [unclosed\n---\n")); err == nil {
+		t.Error("expected an error for invalid front matter")
+	}
+
+	// the front matter title overrides the first heading, and params are available to the template
+	dir := t.TempDir()
+	md_path := filepath.Join(dir, "index.md")
+	err = os.WriteFile(md_path, []byte(md), 0644)
+	testerr(err, t)
+	tmpl_path := filepath.Join(dir, "template.html")
+	err = os.WriteFile(tmpl_path, []byte("{{.Title}}|{{.Params.author}}"), 0644)
+	testerr(err, t)
+	page, err := loadPage(md_path, "/index.md", md_path, Config{Templatedir: tmpl_path}, nil, nil)
+	testerr(err, t)
+	templates, err := loadTemplates(filepath.Join(dir, "partials"), Config{})
+	testerr(err, t)
+	out, err := embedHtmlInTemplate(page, &Site{Pages: []*Page{page}}, nil, templates, nil)
+	testerr(err, t)
+	if string(out) != "Custom Title|someone" {
+		t.Errorf("unexpected template output: %q", out)
+	}
+}
+
+// sets up a fresh workspace in a temporary directory, using the given template,
+// and writes the given files (relative path -> contents) into its source directory.
+func setupWorkspace(t *testing.T, template string, files map[string]string) {
+	WORKING_DIR = t.TempDir()
+	SOURCE_DIR = filepath.Join(WORKING_DIR, "src/")
+	ADDON_DIR = filepath.Join(WORKING_DIR, "addons/")
+	PARTIALS_DIR = filepath.Join(WORKING_DIR, "partials/")
+	DATA_DIR = filepath.Join(WORKING_DIR, "data/")
+	testerr(commandInit(nil), t)
+
+	err := os.WriteFile(filepath.Join(WORKING_DIR, "template.html"), []byte(template), 0644)
+	testerr(err, t)
+	for name, contents := range files {
+		path := filepath.Join(SOURCE_DIR, filepath.FromSlash(name))
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+		testerr(err, t)
+		err = os.WriteFile(path, []byte(contents), 0644)
+		testerr(err, t)
+	}
+}
+
+// overwrites the global config of the workspace set up by setupWorkspace.
+func writeConfig(t *testing.T, conf Config) {
+	yamlData, err := yaml.Marshal(&conf)
+	testerr(err, t)
+	err = os.WriteFile(filepath.Join(WORKING_DIR, "silvera.conf"), yamlData, 0644)
+	testerr(err, t)
+}
+
+// reads a file from the build directory of the workspace set up by setupWorkspace.
+func readBuilt(t *testing.T, name string) string {
+	contents, err := os.ReadFile(filepath.Join(WORKING_DIR, "build", filepath.FromSlash(name)))
+	testerr(err, t)
+	return string(contents)
+}
+
+func TestSitePages(t *testing.T) {
+	setupWorkspace(t, "{{range .Site.Pages}}{{.URL}}:{{.Title}};{{end}}|{{range .Site.PagesByDate}}{{.Title}};{{end}}", map[string]string{
+		"index.md":       "# Home",
+		"blog/old.md":    "---\ndate: 2020-01-01\n---\n# Old Post",
+		"blog/new.md":    "---\ntitle: New Post\ndate: 2022-05-01\n---\n# Heading",
+		"blog/image.png": "not really an image",
+	})
+	testerr(commandBuild(nil), t)
+
+	expected := "/blog/new.html:New Post;/blog/old.html:Old Post;/index.html:Home;|New Post;Old Post;Home;"
+	for _, name := range []string{"index.html", "blog/old.html", "blog/new.html"} {
+		if out := readBuilt(t, name); out != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, out)
+		}
+	}
+	if out := readBuilt(t, "blog/image.png"); out != "not really an image" {
+		t.Errorf("asset not copied: %q", out)
+	}
+}
+
+func TestParallelBuild(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("dir%d/page%d.md", i%3, i)] = fmt.Sprintf("# Page %d\nSome *text*.", i)
+		files[fmt.Sprintf("dir%d/asset%d.txt", i%3, i)] = fmt.Sprintf("asset %d", i)
+	}
+	setupWorkspace(t, "{{range .Site.Pages}}{{.Title}};{{end}}{{.Body}}", files)
+
+	// an addon that logs the order in which the file hooks are called
+	log_path := filepath.Join(WORKING_DIR, "hooks.log")
+	err := os.MkdirAll(filepath.Join(ADDON_DIR, "logger"), 0755)
+	testerr(err, t)
+	err = os.WriteFile(filepath.Join(ADDON_DIR, "logger", "prf__log.sh"), []byte("echo \"pre $(basename $1 .md)\" >> "+log_path), 0755)
+	testerr(err, t)
+	err = os.WriteFile(filepath.Join(ADDON_DIR, "logger", "pof__log.sh"), []byte("echo \"post $(basename $1 .html)\" >> "+log_path), 0755)
+	testerr(err, t)
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	conf.Addons = []string{"logger"}
+	writeConfig(t, conf)
+
+	// the output of a serial build and a parallel build must be identical
+	testerr(commandBuild([]string{"-j", "1"}), t)
+	serial := map[string]string{}
+	for name := range files {
+		name = strings.Replace(name, ".md", ".html", 1)
+		serial[name] = readBuilt(t, name)
+	}
+	testerr(commandBuild([]string{"--force", "-j", "8"}), t)
+	for name, contents := range serial {
+		if out := readBuilt(t, name); out != contents {
+			t.Errorf("%s differs between serial and parallel build: %q != %q", name, out, contents)
+		}
+	}
+
+	// for every file, the pre-file hook has to run before its post-file hook
+	log, err := os.ReadFile(log_path)
+	testerr(err, t)
+	lines := strings.Split(strings.TrimSpace(string(log)), "\n")
+	if len(lines) != 80 {
+		t.Fatalf("expected 80 hook calls, got %d", len(lines))
+	}
+	for i := 0; i < 20; i++ {
+		pre, post := -1, -1
+		for n, line := range lines[40:] { // only look at the parallel build
+			if line == fmt.Sprintf("pre page%d", i) {
+				pre = n
+			} else if line == fmt.Sprintf("post page%d", i) {
+				post = n
+			}
+		}
+		if pre == -1 || post == -1 || pre > post {
+			t.Errorf("hooks of page%d called out of order: pre at %d, post at %d", i, pre, post)
+		}
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	setupWorkspace(t, "{{.Title}}", map[string]string{
+		"a.md":                  "---\ntitle: [unclosed\n---\n# A",
+		"b.md":                  "# B",
+		"sub/.slv/silvera.conf": "outdir: [broken",
+		"sub/c.md":              "# C",
+	})
+
+	// by default, all other files are still built, and all errors are returned together
+	err := commandBuild(nil)
+	build_errs, ok := err.(BuildErrors)
+	if !ok || len(build_errs) != 2 {
+		t.Fatalf("expected two build errors, got %v", err)
+	}
+	if build_errs[0].Path != filepath.Join(SOURCE_DIR, "a.md") || build_errs[1].Path != filepath.Join(SOURCE_DIR, "sub/.slv/silvera.conf") {
+		t.Errorf("errors reported for the wrong files: %v", err)
+	}
+	if readBuilt(t, "b.html") != "B" || readBuilt(t, "sub/c.html") != "C" {
+		t.Error("files without errors were not built")
+	}
+
+	// with --fail-fast, the build stops at the first error
+	err = os.RemoveAll(filepath.Join(WORKING_DIR, "build"))
+	testerr(err, t)
+	err = commandBuild([]string{"--fail-fast", "--force", "-j", "1"})
+	if build_errs, ok := err.(BuildErrors); !ok || len(build_errs) != 1 {
+		t.Fatalf("expected a single build error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(WORKING_DIR, "build", "b.html")); err == nil {
+		t.Error("build did not stop at the first error")
+	}
+
+	// without a global config, there is nothing to build at all
+	err = os.Remove(filepath.Join(WORKING_DIR, "silvera.conf"))
+	testerr(err, t)
+	if err := commandBuild(nil); err == nil {
+		t.Error("expected an error for a missing config")
+	}
+}
+
+func TestNestedConfigs(t *testing.T) {
+	setupWorkspace(t, "default:{{.Body}}", map[string]string{
+		"top.md":                     "~~x~~\ny",
+		"a/a.md":                     "~~x~~\ny",
+		"a/b/b.md":                   "~~x~~\ny",
+		"a/b/c/c.md":                 "~~x~~\ny",
+		"a/.slv/template.html":       "a:{{.Body}}",
+		"a/b/.slv/silvera.conf":      "extensions:\n  strikethrough: false",
+		"a/b/c/.slv/silvera.conf":    "renderer_options:\n  hard_wraps: true",
+		"a/b/c/.slv/placeholder.txt": "not copied",
+	})
+	err := os.WriteFile(filepath.Join(SOURCE_DIR, "a/.slv/silvera.conf"), []byte("template: "+filepath.Join(SOURCE_DIR, "a/.slv/template.html")), 0644)
+	testerr(err, t)
+	testerr(commandBuild(nil), t)
+
+	// each level inherits everything its ancestors set, and adds its own changes
+	expected := map[string]string{
+		"top.html":     "default:<p><del>x</del>\ny</p>\n",
+		"a/a.html":     "a:<p><del>x</del>\ny</p>\n",
+		"a/b/b.html":   "a:<p>~~x~~\ny</p>\n",
+		"a/b/c/c.html": "a:<p>~~x~~<br />\ny</p>\n",
+	}
+	for name, contents := range expected {
+		if out := readBuilt(t, name); out != contents {
+			t.Errorf("%s: expected %q, got %q", name, contents, out)
+		}
+	}
+
+	// the result must not depend on the order in which the configs are found
+	global, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	confPaths := map[string]string{}
+	for _, dir := range []string{"a/b/c", "a", "a/b"} {
+		confPaths[filepath.Join(SOURCE_DIR, dir)] = filepath.Join(SOURCE_DIR, dir, HIDDEN_DIR, "silvera.conf")
+	}
+	for i := 0; i < 5; i++ {
+		configs := readLocalConfigs(confPaths, global, &errorReport{})
+		c := configs[filepath.Join(SOURCE_DIR, "a/b/c")]
+		if c.Extensions.Strikethrough || !c.RendererOptions.WithHardWraps || !strings.HasSuffix(c.Templatedir, "a/.slv/template.html") {
+			t.Errorf("config not fully inherited: %+v", c)
+		}
+	}
+}
+
+func TestConfigMerging(t *testing.T) {
+	parent := Config{
+		Outdir:          "build",
+		Extensions:      Exts{Table: true, Strikethrough: true},
+		RendererOptions: RendererOpts{WithXHTML: true},
+		Addons:          []string{"a", "b"},
+	}
+	path := filepath.Join(t.TempDir(), "silvera.conf")
+	local := "extensions:\n  tables: false\nrenderer_options:\naddons_append: [c, b]\naddons_remove: a\n"
+	err := os.WriteFile(path, []byte(local), 0644)
+	testerr(err, t)
+
+	conf, err := readConfigFile(path, parent)
+	testerr(err, t)
+	// set keys override, unset and empty keys inherit
+	if conf.Extensions.Table || !conf.Extensions.Strikethrough || !conf.RendererOptions.WithXHTML || conf.Outdir != "build" {
+		t.Errorf("config not merged correctly: %+v", conf)
+	}
+	// lists are extended and shortened, without duplicates
+	if strings.Join(conf.Addons, ",") != "b,c" {
+		t.Errorf("unexpected addons: %v", conf.Addons)
+	}
+	// the parent is left untouched
+	if strings.Join(parent.Addons, ",") != "a,b" || !parent.Extensions.Table {
+		t.Errorf("parent config was modified: %+v", parent)
+	}
+
+	// replacing a list and appending to it can be combined
+	err = os.WriteFile(path, []byte("addons: [x]\naddons_append: [z]\n"), 0644)
+	testerr(err, t)
+	conf, err = readConfigFile(path, parent)
+	testerr(err, t)
+	if strings.Join(conf.Addons, ",") != "x,z" {
+		t.Errorf("unexpected addons: %v", conf.Addons)
+	}
+
+	// values of the wrong type are reported
+	err = os.WriteFile(path, []byte("extensions:\n  tables: [1, 2]\n"), 0644)
+	testerr(err, t)
+	if _, err := readConfigFile(path, parent); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+// IMPORTS
+import (
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//// PAGINATION
+// listing pages, like the index of a blog, can be split into several pages of a fixed size.
+// A page lists the pages below a directory by putting 'paginate: DIR' in its front matter ('paginate: true' lists its
+// own directory). The first page is written to the usual place, the others to 'page/2/index.html' and so on, next to it.
+// Term pages of taxonomies are split the same way. Templates access the current page through {{.Paginator}}.
+// The page size is set in the 'pagination' section of 'silvera.conf', and can be changed per directory.
+// -----------------------------------------------------------------------------------
+
+const DEFAULT_PAGE_SIZE = 10 // the page size used if none is configured
+
+// this struct holds a single page of a split listing.
+type Paginator struct {
+	Items    []*Page // the pages listed on this page
+	Current  int     // the number of this page, starting at 1
+	Total    int     // the total number of pages
+	URL      string  // the url of this page
+	FirstURL string  // the url of the first page
+	LastURL  string  // the url of the last page
+	PrevURL  string  // the url of the previous page, empty on the first page
+	NextURL  string  // the url of the next page, empty on the last page
+
+	outPath string // the path this page is written to
+}
+
+// returns the configured page size, or DEFAULT_PAGE_SIZE if there is none.
+func pageSize(conf PaginationConf) int {
+	if conf.PageSize > 0 {
+		return conf.PageSize
+	}
+	return DEFAULT_PAGE_SIZE
+}
+
+// returns the url and the directory in the build directory that the further pages of a listing are placed in.
+// for a listing at "/blog/index.html" (or "/blog/"), the second page is at "/blog/page/2/".
+// for a listing at "/blog/archive.html", it is at "/blog/archive/page/2/".
+func paginationBase(url string, out_path string) (string, string) {
+	if strings.HasSuffix(url, "/index.html") {
+		url = strings.TrimSuffix(url, "index.html")
+	}
+	if strings.HasSuffix(url, "/") {
+		return url, filepath.Dir(out_path)
+	}
+	return strings.TrimSuffix(url, ".html") + "/", strings.TrimSuffix(out_path, ".html")
+}
+
+// splits the given items into pages of the given size. The first page is the listing itself,
+// at first_url and first_out_path. A listing without items still has a single, empty page.
+func paginate(items []*Page, size int, first_url string, first_out_path string) []*Paginator {
+	total := (len(items) + size - 1) / size
+	if total == 0 {
+		total = 1
+	}
+	base_url, base_dir := paginationBase(first_url, first_out_path)
+
+	paginators := make([]*Paginator, total)
+	for i := range paginators {
+		paginator := &Paginator{Current: i + 1, Total: total, URL: first_url, outPath: first_out_path}
+		if i > 0 {
+			paginator.URL = base_url + "page/" + strconv.Itoa(i+1) + "/"
+			paginator.outPath = filepath.Join(base_dir, "page", strconv.Itoa(i+1), "index.html")
+		}
+		start, end := i*size, (i+1)*size
+		if end > len(items) {
+			end = len(items)
+		}
+		paginator.Items = items[start:end]
+		paginators[i] = paginator
+	}
+
+	for i, paginator := range paginators {
+		paginator.FirstURL = paginators[0].URL
+		paginator.LastURL = paginators[total-1].URL
+		if i > 0 {
+			paginator.PrevURL = paginators[i-1].URL
+		}
+		if i < total-1 {
+			paginator.NextURL = paginators[i+1].URL
+		}
+	}
+	return paginators
+}
+
+// returns the pages below the given directory (relative to 'src'), newest first.
+func pagesBelow(dir string, site *Site) []*Page {
+	prefix := dirURL(dir)
+	var pages []*Page
+	for _, page := range site.Pages {
+		if strings.HasPrefix(filepath.ToSlash(page.relpath), prefix) {
+			pages = append(pages, page)
+		}
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].lastModified().After(pages[j].lastModified())
+	})
+	return pages
+}
+
+// splits every page with 'paginate' in its front matter into the pages of its listing.
+// listing pages are never part of a listing themselves, so a blog index doesn't list itself.
+func buildPaginators(site *Site) {
+	listings := make(map[*Page]string) // the directory listed by each listing page
+	for _, page := range site.Pages {
+		switch value := page.Params["paginate"].(type) {
+		case bool:
+			if value {
+				listings[page] = path.Dir(filepath.ToSlash(page.relpath))
+			}
+		case string:
+			listings[page] = value
+		}
+	}
+
+	for page, dir := range listings {
+		var items []*Page
+		for _, item := range pagesBelow(dir, site) {
+			if _, ok := listings[item]; !ok {
+				items = append(items, item)
+			}
+		}
+		page.paginators = paginate(items, pageSize(page.conf.Pagination), page.URL, page.outPath)
+	}
+}
+
+// returns the body of a listing page as it is embedded on the given page of the listing.
+// the relative links of the body were written for the first page, so they are rewritten to work from the
+// directory of the further pages. Links to '#fragments' point to the body itself, so they are kept.
+func paginatedBody(page *Page, paginator *Paginator) []byte {
+	if paginator.URL == page.URL {
+		return page.body
+	}
+	return rewriteRelativeLinks(page.body, page.URL, func(site_url string, ref string) string {
+		if strings.HasPrefix(ref, "#") {
+			return ref
+		}
+		parsed, err := url.Parse(site_url)
+		if err != nil {
+			return ref
+		}
+		parsed.Path = relativeURL(paginator.URL, parsed.Path)
+		return parsed.String()
+	})
+}
+
+// reports whether all pages of a listing were written already.
+func paginatorsExist(paginators []*Paginator) bool {
+	for _, paginator := range paginators {
+		if _, err := os.Stat(paginator.outPath); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPagination(t *testing.T) {
+	setupWorkspace(t, "{{with .Paginator}}{{.Current}}/{{.Total}} {{.PrevURL}} {{.NextURL}}:{{range .Items}}{{.Title}};{{end}}{{end}}", map[string]string{
+		"archive.md":             "---\ntitle: Archive\npaginate: blog\n---\n",
+		"blog/index.md":          "---\ntitle: Blog\npaginate: true\n---\n",
+		"blog/.slv/silvera.conf": "pagination:\n  page_size: 2",
+		"blog/a.md":              "---\ntitle: A\ndate: 2022-01-01\ntags: x\n---\n",
+		"blog/b.md":              "---\ntitle: B\ndate: 2022-01-02\ntags: x\n---\n",
+		"blog/c.md":              "---\ntitle: C\ndate: 2022-01-03\ntags: x\n---\n",
+		"blog/d.md":              "---\ntitle: D\ndate: 2022-01-04\ntags: x\n---\n",
+		"blog/e.md":              "---\ntitle: E\ndate: 2022-01-05\ntags: x\n---\n",
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	conf.Pagination.PageSize = 3
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+
+	expected := map[string]string{
+		// the local config of the blog overrides the page size
+		"blog/index.html":           "1/3  /blog/page/2/:E;D;",
+		"blog/page/2/index.html":    "2/3 /blog/index.html /blog/page/3/:C;B;",
+		"blog/page/3/index.html":    "3/3 /blog/page/2/ :A;",
+		"archive.html":              "1/2  /archive/page/2/:E;D;C;",
+		"archive/page/2/index.html": "2/2 /archive.html :B;A;",
+		// taxonomy terms are split as well
+		"tags/x/index.html":        "1/2  /tags/x/page/2/:E;D;C;",
+		"tags/x/page/2/index.html": "2/2 /tags/x/ :B;A;",
+		// pages that don't list anything have no paginator
+		"blog/a.html": "",
+	}
+	for name, out := range expected {
+		if built := readBuilt(t, name); built != out {
+			t.Errorf("%s: expected %q, got %q", name, out, built)
+		}
+	}
+
+	// missing pages of a listing are written again, even if nothing changed
+	testerr(os.Remove(filepath.Join(WORKING_DIR, "build", "blog", "page", "3", "index.html")), t)
+	testerr(commandBuild(nil), t)
+	if built := readBuilt(t, "blog/page/3/index.html"); built != expected["blog/page/3/index.html"] {
+		t.Errorf("missing page not rebuilt: %q", built)
+	}
+}
+
+func TestPaginatedLinks(t *testing.T) {
+	setupWorkspace(t, "{{.Body}}", map[string]string{
+		"blog/index.md": "---\npaginate: true\n---\n[about](about.md) ![](pic.png) [top](#top) [home](/index.md)",
+		"blog/about.md": "# About",
+		"blog/post.md":  "# Post",
+		"blog/pic.png":  "pixels",
+		"index.md":      "",
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	conf.Pagination.PageSize = 1
+	conf.CheckLinks = true
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t) // the link check finds no broken links on any page of the listing
+
+	expected := map[string]string{
+		"blog/index.html":        `<p><a href="about.html">about</a> <img src="pic.png" alt="" /> <a href="#top">top</a> <a href="../index.html">home</a></p>` + "\n",
+		"blog/page/2/index.html": `<p><a href="../../about.html">about</a> <img src="../../pic.png" alt=""/> <a href="#top">top</a> <a href="../../../index.html">home</a></p>` + "\n",
+	}
+	for name, out := range expected {
+		if built := readBuilt(t, name); built != out {
+			t.Errorf("%s: expected %q, got %q", name, out, built)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+// IMPORTS
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//// PUBLISHING
+// pages don't have to go live as soon as they are placed in the source directory.
+// A page with 'draft: true' in its front matter is left out of the build, as is a page whose 'publish_date'
+// (or 'date', if it has no 'publish_date') lies in the future, and a page whose 'expiry_date' has passed.
+// Left out pages are neither rendered nor linked to, and don't show up in feeds, the sitemap or taxonomies.
+// 'silvera build --drafts', '--future' and '--expired' include them anyway, e.g. to preview them.
+// The same goes for the other commands that look at the pages of the site, like 'graph'.
+// -----------------------------------------------------------------------------------
+
+// this struct describes a Markdown file that is left out of the build.
+type unpublishedPage struct {
+	job     buildJob // the job the page would have been built by
+	reason  string   // why the page is left out, like "draft"
+	listing bool     // whether the page is a listing, which may have been split into several files
+}
+
+// registers the flags that include unpublished pages on the given flag set.
+func addPublishFlags(flags *flag.FlagSet, opts *BuildOptions) {
+	flags.BoolVar(&opts.Drafts, "drafts", false, "include pages marked as drafts")
+	flags.BoolVar(&opts.Future, "future", false, "include pages with a publish date in the future")
+	flags.BoolVar(&opts.Expired, "expired", false, "include pages whose expiry date has passed")
+}
+
+// returns why the page with the given front matter must not be published at the given time,
+// or an empty string if it may be published.
+func unpublishedReason(params map[string]interface{}, opts BuildOptions, now time.Time) string {
+	if draft, ok := params["draft"].(bool); ok && draft && !opts.Drafts {
+		return "draft"
+	}
+
+	publish_date := parseDate(params["publish_date"])
+	if publish_date.IsZero() {
+		publish_date = parseDate(params["date"])
+	}
+	if publish_date.After(now) && !opts.Future {
+		return "scheduled for " + publish_date.Format(time.RFC3339)
+	}
+
+	expiry_date := parseDate(params["expiry_date"])
+	if !expiry_date.IsZero() && !expiry_date.After(now) && !opts.Expired {
+		return "expired on " + expiry_date.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// removes the Markdown files that must not be published yet (or anymore) from the given jobs,
+// returning the remaining jobs and the left out pages.
+// the front matter is read here, before any page is rendered, so that other pages can't link to the left out ones.
+// files whose front matter can't be read are kept, the error is reported when the page is loaded.
+func filterUnpublished(jobs []buildJob, opts BuildOptions) ([]buildJob, []unpublishedPage) {
+	now := time.Now()
+	var published []buildJob
+	var unpublished []unpublishedPage
+	for _, job := range jobs {
+		if job.isDir || !strings.HasSuffix(job.relpath, ".md") {
+			published = append(published, job)
+			continue
+		}
+		file_bytes, err := ioutil.ReadFile(job.srcPath)
+		if err != nil {
+			published = append(published, job)
+			continue
+		}
+		params, _, err := splitFrontMatter(file_bytes)
+		if err != nil {
+			published = append(published, job)
+			continue
+		}
+		reason := unpublishedReason(params, opts, now)
+		if reason == "" {
+			published = append(published, job)
+			continue
+		}
+
+		_, listing := params["paginate"]
+		unpublished = append(unpublished, unpublishedPage{job: job, reason: reason, listing: listing})
+	}
+	return published, unpublished
+}
+
+// removes the outputs of left out pages that were built before, so they don't stay online.
+// besides the page itself, this is the directory of its own it gets with pretty urls,
+// and for listings, the further pages of the listing (see pagination.go).
+func removeUnpublished(pages []unpublishedPage, report *errorReport) {
+	remove := func(path string) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			report.add(path, err)
+		}
+	}
+	for _, page := range pages {
+		fmt.Printf("skipped: %s (%s)\n", page.job.relpath, page.reason)
+		out_path := pageOutPath(page.job.outpath, page.job.conf)
+		remove(out_path)
+
+		if page.listing {
+			_, base_dir := paginationBase(pageURL(page.job.relpath, page.job.conf), out_path)
+			for n := 2; ; n++ {
+				page_dir := filepath.Join(base_dir, "page", strconv.Itoa(n))
+				if _, err := os.Stat(page_dir); err != nil {
+					break
+				}
+				remove(filepath.Join(page_dir, "index.html"))
+				os.Remove(page_dir) // directories are only removed if nothing else was placed in them
+			}
+			os.Remove(filepath.Join(base_dir, "page"))
+		}
+
+		// with pretty urls, a page that is not an index has a directory of its own
+		if filepath.Base(out_path) == "index.html" && filepath.Base(page.job.relpath) != "index.md" {
+			os.Remove(filepath.Dir(out_path))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnpublishedPages(t *testing.T) {
+	future := time.Now().AddDate(1, 0, 0).Format("2006-01-02")
+	setupWorkspace(t, "{{.Title}}:{{range .Site.Pages}}{{.Title}};{{end}}", map[string]string{
+		"index.md":     "---\ntitle: Index\n---\n",
+		"draft.md":     "---\ntitle: Draft\ndraft: true\n---\n",
+		"scheduled.md": "---\ntitle: Scheduled\ndate: 2020-01-01\npublish_date: " + future + "\n---\n",
+		"upcoming.md":  "---\ntitle: Upcoming\ndate: " + future + "\n---\n",
+		"expired.md":   "---\ntitle: Expired\nexpiry_date: 2020-01-01\n---\n",
+		"current.md":   "---\ntitle: Current\ndate: 2020-01-01\nexpiry_date: " + future + "\n---\n",
+	})
+	unpublished := []string{"draft.html", "scheduled.html", "upcoming.html", "expired.html"}
+
+	testerr(commandBuild(nil), t)
+	if index := readBuilt(t, "index.html"); index != "Index:Current;Index;" {
+		t.Errorf("unexpected index: %q", index)
+	}
+	for _, name := range unpublished {
+		if _, err := os.Stat(filepath.Join(WORKING_DIR, "build", name)); err == nil {
+			t.Errorf("%s was built", name)
+		}
+	}
+
+	testerr(commandBuild([]string{"--drafts", "--future", "--expired"}), t)
+	if index := readBuilt(t, "index.html"); index != "Index:Current;Draft;Expired;Index;Scheduled;Upcoming;" {
+		t.Errorf("unexpected index: %q", index)
+	}
+
+	// pages that were built before are removed from the build again
+	testerr(commandBuild([]string{"--future"}), t)
+	for _, name := range []string{"draft.html", "expired.html"} {
+		if _, err := os.Stat(filepath.Join(WORKING_DIR, "build", name)); err == nil {
+			t.Errorf("%s was not removed", name)
+		}
+	}
+	for _, name := range []string{"scheduled.html", "upcoming.html"} {
+		if _, err := os.Stat(filepath.Join(WORKING_DIR, "build", name)); err != nil {
+			t.Errorf("%s was not built: %v", name, err)
+		}
+	}
+}
+
+func TestUnpublishedGraph(t *testing.T) {
+	setupWorkspace(t, "{{.Body}}", map[string]string{
+		"index.md": "[Draft](draft.md)",
+		"draft.md": "---\ndraft: true\n---\n",
+	})
+	graph_path := filepath.Join(WORKING_DIR, "graph.json")
+
+	testerr(commandGraph([]string{"--format", "json"}), t)
+	graph, err := os.ReadFile(graph_path)
+	testerr(err, t)
+	if strings.Contains(string(graph), "draft") {
+		t.Errorf("draft in graph: %s", graph)
+	}
+
+	testerr(commandGraph([]string{"--format", "json", "--drafts"}), t)
+	graph, err = os.ReadFile(graph_path)
+	testerr(err, t)
+	if !strings.Contains(string(graph), `"target": "/draft.md"`) {
+		t.Errorf("draft missing from graph: %s", graph)
+	}
+}
+
+func TestUnpublishedOutputsRemoved(t *testing.T) {
+	setupWorkspace(t, "{{.Body}}", map[string]string{
+		"blog/archive.md": "---\npaginate: blog\n---\n",
+		"blog/a.md":       "# A",
+		"blog/b.md":       "# B",
+		"blog/c.md":       "# C",
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	conf.URLStyle = URL_STYLE_PRETTY
+	conf.Pagination.PageSize = 1
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+	archive_dir := filepath.Join(WORKING_DIR, "build", "blog", "archive")
+	for _, name := range []string{"index.html", "page/2/index.html", "page/3/index.html"} {
+		if _, err := os.Stat(filepath.Join(archive_dir, filepath.FromSlash(name))); err != nil {
+			t.Fatalf("listing not built: %v", err)
+		}
+	}
+
+	// the listing and its further pages are removed, together with the directories they were placed in
+	err = os.WriteFile(filepath.Join(SOURCE_DIR, "blog", "archive.md"), []byte("---\npaginate: blog\ndraft: true\n---\n"), 0644)
+	testerr(err, t)
+	testerr(commandBuild(nil), t)
+	if _, err := os.Stat(archive_dir); !os.IsNotExist(err) {
+		t.Errorf("listing directory left behind: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(WORKING_DIR, "build", "blog", "a", "index.html")); err != nil {
+		t.Errorf("published page removed: %v", err)
+	}
+}
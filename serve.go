@@ -0,0 +1,248 @@
+package main
+
+// IMPORTS
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//// SERVE COMMAND
+// the 'serve' command builds the site, serves the build directory over a local http server,
+// and rebuilds the site whenever a source file changes. Open browser tabs are then told
+// to reload the page, using a small script that is injected into every served html file.
+// -----------------------------------------------------------------------------------
+
+const (
+	RELOAD_PATH   = "/__silvera/reload"    // the url path of the server-sent-events endpoint
+	POLL_INTERVAL = 500 * time.Millisecond // how often the watched files are checked for changes
+)
+
+// this script is injected into every served html file. It listens for reload events from the server.
+const RELOAD_SCRIPT = "<script>new EventSource(\"" + RELOAD_PATH + "\").onmessage = function() { location.reload(); };</script>"
+
+// this struct keeps track of the connected browsers, so they can be told to reload.
+type reloadBroadcaster struct {
+	mutex   sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+// registers a new client, returning the channel it will be notified on.
+func (b *reloadBroadcaster) subscribe() chan struct{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	ch := make(chan struct{}, 1)
+	b.clients[ch] = true
+	return ch
+}
+
+func (b *reloadBroadcaster) unsubscribe(ch chan struct{}) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.clients, ch)
+}
+
+// notifies all connected clients. Clients that still have a pending notification are skipped.
+func (b *reloadBroadcaster) broadcast() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for ch := range b.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// this handler keeps a server-sent-events connection open, and sends a message whenever a reload is due.
+func (b *reloadBroadcaster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	ch := b.subscribe()
+	defer b.unsubscribe(ch)
+	for {
+		select {
+		case <-ch:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// inserts the reload script right before the closing body tag, or appends it if there is none.
+func injectReloadScript(html []byte) []byte {
+	idx := bytes.LastIndex(bytes.ToLower(html), []byte("</body>"))
+	if idx == -1 {
+		return append(html, []byte(RELOAD_SCRIPT)...)
+	}
+	var buf bytes.Buffer
+	buf.Write(html[:idx])
+	buf.WriteString(RELOAD_SCRIPT)
+	buf.Write(html[idx:])
+	return buf.Bytes()
+}
+
+// this handler serves the files from the build directory. html files get the reload script injected,
+// everything else is handed to the standard file server.
+func serveBuildDir(outdir string) http.Handler {
+	fileServer := http.FileServer(http.Dir(outdir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file_path := filepath.Join(outdir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		if info, err := os.Stat(file_path); err == nil && info.IsDir() {
+			// relative links only work from directory urls ending in a slash (like pretty urls), so redirect to those
+			if !strings.HasSuffix(r.URL.Path, "/") {
+				http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+				return
+			}
+			file_path = filepath.Join(file_path, "index.html")
+		}
+
+		if !strings.HasSuffix(file_path, ".html") {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		html, err := ioutil.ReadFile(file_path)
+		if err != nil {
+			fileServer.ServeHTTP(w, r) // let the file server produce the fitting error (or redirect)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(injectReloadScript(html))
+	})
+}
+
+// this struct is used to detect changes to a file, without having to read its contents.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// records the state of all files below the given paths. Paths that don't exist are ignored.
+// the directories in skip (like the build directory) are not recorded, as they change with every build.
+// neither are '.git' directories, which change without the site being affected.
+func snapshotFiles(paths []string, skip []string) map[string]fileStamp {
+	snapshot := make(map[string]fileStamp)
+	for _, root := range paths {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() && info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			for _, skipped := range skip {
+				if info.IsDir() && filepath.Clean(path) == filepath.Clean(skipped) {
+					return filepath.SkipDir
+				}
+			}
+			snapshot[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+			return nil
+		})
+	}
+	return snapshot
+}
+
+// returns whether two snapshots differ, meaning a file was added, removed or changed.
+func snapshotsDiffer(a map[string]fileStamp, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return true
+	}
+	for path, stamp := range a {
+		if other, ok := b[path]; !ok || !other.modTime.Equal(stamp.modTime) || other.size != stamp.size {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the files and directories that trigger a rebuild when changed, and the directories below them that don't.
+// templates may be placed anywhere in the workspace (see pageTemplate), so all of it is watched,
+// apart from the build directory and the build cache, which are written by the build itself.
+// templates outside the workspace are watched if the global config names them.
+func watchedPaths(config Config) ([]string, []string) {
+	watched := []string{WORKING_DIR}
+	templates := []string{config.Templatedir, filepath.Dir(config.Templatedir)}
+	for _, taxonomy := range config.Taxonomies {
+		templates = append(templates, taxonomyTemplate(taxonomy.Template, config), taxonomyTemplate(taxonomy.ListTemplate, config))
+	}
+	for _, path := range templates {
+		if rel, err := filepath.Rel(WORKING_DIR, path); err != nil || strings.HasPrefix(rel, "..") {
+			watched = append(watched, path)
+		}
+	}
+
+	outdir := config.Outdir
+	if !filepath.IsAbs(outdir) {
+		outdir = filepath.Join(WORKING_DIR, outdir) // relative to the workspace, which is the current directory
+	}
+	return watched, []string{outdir, filepath.Join(WORKING_DIR, HIDDEN_DIR, "cache")}
+}
+
+// the 'serve' command, as described above.
+// a failing build doesn't stop the server, the errors are just printed.
+func commandServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	port := flags.Int("port", 8080, "the port to serve the site on")
+	var opts BuildOptions
+	addPublishFlags(flags, &opts)
+	flags.Parse(args)
+
+	if err := buildSite(opts); err != nil {
+		fmt.Println("Err:", err)
+	}
+
+	config, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	if err != nil {
+		return err
+	}
+
+	watched, skipped := watchedPaths(config)
+
+	broadcaster := &reloadBroadcaster{clients: make(map[chan struct{}]bool)}
+
+	// poll the watched files, and rebuild whenever something changed
+	go func() {
+		last := snapshotFiles(watched, skipped)
+		for {
+			time.Sleep(POLL_INTERVAL)
+			current := snapshotFiles(watched, skipped)
+			if !snapshotsDiffer(last, current) {
+				continue
+			}
+			last = current
+
+			fmt.Println("Change detected, rebuilding...")
+			if err := buildSite(opts); err != nil {
+				fmt.Println("Err:", err)
+				continue
+			}
+			broadcaster.broadcast()
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle(RELOAD_PATH, broadcaster)
+	mux.Handle("/", serveBuildDir(config.Outdir))
+
+	addr := fmt.Sprintf("localhost:%d", *port)
+	fmt.Printf("Serving %s at http://%s\n", config.Outdir, addr)
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInjectReloadScript(t *testing.T) {
+	out := string(injectReloadScript([]byte("<html><body><p>hi</p></BODY></html>")))
+	if out != "<html><body><p>hi</p>"+RELOAD_SCRIPT+"</BODY></html>" {
+		t.Errorf("script not injected before the body end: %q", out)
+	}
+
+	out = string(injectReloadScript([]byte("<p>fragment</p>")))
+	if out != "<p>fragment</p>"+RELOAD_SCRIPT {
+		t.Errorf("script not appended: %q", out)
+	}
+}
+
+func TestServeBuildDir(t *testing.T) {
+	dir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	testerr(err, t)
+	err = os.WriteFile(filepath.Join(dir, "sub", "index.html"), []byte("<body></body>"), 0644)
+	testerr(err, t)
+	err = os.WriteFile(filepath.Join(dir, "style.css"), []byte("body {}"), 0644)
+	testerr(err, t)
+
+	server := httptest.NewServer(serveBuildDir(dir))
+	defer server.Close()
+
+	get := func(path string) string {
+		resp, err := server.Client().Get(server.URL + path)
+		testerr(err, t)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		testerr(err, t)
+		return string(body)
+	}
+
+	if body := get("/sub/"); !strings.Contains(body, RELOAD_SCRIPT) {
+		t.Errorf("directory index not injected: %q", body)
+	}
+	if body := get("/sub"); !strings.Contains(body, RELOAD_SCRIPT) {
+		t.Errorf("directory without a trailing slash not redirected: %q", body)
+	}
+	if body := get("/style.css"); body != "body {}" {
+		t.Errorf("non-html file changed: %q", body)
+	}
+}
+
+func TestSnapshotFiles(t *testing.T) {
+	dir := t.TempDir()
+	build := filepath.Join(dir, "build")
+	err := os.MkdirAll(build, 0755)
+	testerr(err, t)
+	file := filepath.Join(dir, "index.md")
+	err = os.WriteFile(file, []byte("# Hi"), 0644)
+	testerr(err, t)
+
+	before := snapshotFiles([]string{dir}, []string{build})
+	err = os.WriteFile(filepath.Join(build, "index.html"), []byte("<h1>Hi</h1>"), 0644)
+	testerr(err, t)
+	if snapshotsDiffer(before, snapshotFiles([]string{dir}, []string{build})) {
+		t.Error("changes in the build directory should be ignored")
+	}
+
+	err = os.WriteFile(file, []byte("# Hello"), 0644)
+	testerr(err, t)
+	err = os.Chtimes(file, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	testerr(err, t)
+	if !snapshotsDiffer(before, snapshotFiles([]string{dir}, []string{build})) {
+		t.Error("changed source file not detected")
+	}
+}
+
+func TestWatchedPaths(t *testing.T) {
+	setupWorkspace(t, "{{.Body}}", map[string]string{"index.md": "# Hi"})
+	outside := filepath.Join(t.TempDir(), "tags.html")
+	testerr(os.WriteFile(outside, []byte("{{.Body}}"), 0644), t)
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	conf.Outdir = "build" // relative to the workspace
+	conf.Taxonomies = []TaxonomyConf{{Name: "tags", Template: outside}}
+	testerr(commandBuild(nil), t)
+
+	watched, skipped := watchedPaths(conf)
+	snapshot := func() map[string]fileStamp { return snapshotFiles(watched, skipped) }
+	changed := func(path string) bool {
+		before := snapshot()
+		testerr(os.MkdirAll(filepath.Dir(path), 0755), t)
+		testerr(os.WriteFile(path, []byte(path), 0644), t)
+		testerr(os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute)), t)
+		return snapshotsDiffer(before, snapshot())
+	}
+
+	// templates anywhere in the workspace, and those named in the config, trigger a rebuild
+	for _, path := range []string{filepath.Join(WORKING_DIR, "layouts", "post.html"), outside} {
+		if !changed(path) {
+			t.Errorf("change to %s not detected", path)
+		}
+	}
+	// the build itself doesn't
+	for _, path := range []string{filepath.Join(WORKING_DIR, "build", "new.html"), filepath.Join(WORKING_DIR, HIDDEN_DIR, "cache", "new")} {
+		if changed(path) {
+			t.Errorf("change to %s detected", path)
+		}
+	}
+}
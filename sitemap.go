@@ -0,0 +1,107 @@
+package main
+
+// IMPORTS
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//// SITEMAP
+// the following functions generate a sitemap.xml listing all pages of the site, and optionally
+// a robots.txt pointing to it, as configured in the 'sitemap' section of the global 'silvera.conf'.
+// Pages can be left out using 'sitemap: false' in their front matter, or 'sitemap: {exclude: true}'
+// in a local config.
+// -----------------------------------------------------------------------------------
+
+// the following structs describe a sitemap, as written by encoding/xml.
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+}
+
+// reports whether a page should be listed in the sitemap.
+func inSitemap(page *Page) bool {
+	if page.conf.Sitemap.Exclude {
+		return false
+	}
+	if include, ok := page.Params["sitemap"].(bool); ok && !include {
+		return false
+	}
+	return true
+}
+
+// builds the sitemap for all pages of the site that are not excluded.
+func buildSitemap(config Config, site *Site) ([]byte, error) {
+	urlset := sitemapURLSet{URLs: []sitemapURL{}}
+	for _, page := range site.Pages {
+		if !inSitemap(page) {
+			continue
+		}
+		url := sitemapURL{
+			Loc:        absoluteURL(config.BaseURL, page.URL),
+			ChangeFreq: page.conf.Sitemap.ChangeFreq,
+		}
+		if lastmod := page.lastModified(); !lastmod.IsZero() {
+			url.LastMod = lastmod.Format(time.RFC3339)
+		}
+		urlset.URLs = append(urlset.URLs, url)
+	}
+
+	out, err := xml.MarshalIndent(urlset, "", "  ")
+	return append([]byte(xml.Header), out...), err
+}
+
+// builds a robots.txt that allows everything but the disallowed paths, and points to the sitemap.
+func buildRobots(config Config) []byte {
+	var sb strings.Builder
+	sb.WriteString("User-agent: *\n")
+	if len(config.Sitemap.Disallow) == 0 {
+		sb.WriteString("Disallow:\n")
+	}
+	for _, path := range config.Sitemap.Disallow {
+		sb.WriteString("Disallow: " + path + "\n")
+	}
+	sb.WriteString("\nSitemap: " + absoluteURL(config.BaseURL, "/sitemap.xml") + "\n")
+	return []byte(sb.String())
+}
+
+// writes the sitemap and robots.txt to the outdir, if they are enabled in the given config.
+// errors are added to the report.
+func writeSitemap(config Config, site *Site, report *errorReport) {
+	if !config.Sitemap.Enabled {
+		return
+	}
+	if config.BaseURL == "" {
+		fmt.Println("Warning: no base_url is set in silvera.conf, the sitemap will contain relative links.")
+	}
+
+	sitemap_path := filepath.Join(config.Outdir, "sitemap.xml")
+	out, err := buildSitemap(config, site)
+	if err == nil {
+		err = ioutil.WriteFile(sitemap_path, out, 0644)
+	}
+	if err != nil {
+		report.add(sitemap_path, err)
+		return
+	}
+	fmt.Println("sitemap:", sitemap_path)
+
+	if config.Sitemap.Robots {
+		robots_path := filepath.Join(config.Outdir, "robots.txt")
+		if err := ioutil.WriteFile(robots_path, buildRobots(config), 0644); err != nil {
+			report.add(robots_path, err)
+			return
+		}
+		fmt.Println("robots:", robots_path)
+	}
+}
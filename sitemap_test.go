@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSitemap(t *testing.T) {
+	setupWorkspace(t, "{{.Body}}", map[string]string{
+		"index.md":                  "---\ndate: 2022-03-04\n---\n# Home",
+		"hidden.md":                 "---\nsitemap: false\n---\n# Hidden",
+		"private/.slv/silvera.conf": "sitemap:\n  exclude: true",
+		"private/page.md":           "# Private",
+		"public/page.md":            "# Public",
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	testerr(commandBuild(nil), t)
+	if _, err := os.Stat(filepath.Join(WORKING_DIR, "build", "sitemap.xml")); err == nil {
+		t.Error("sitemap generated without being enabled")
+	}
+
+	conf.BaseURL = "https://example.org"
+	conf.Sitemap = SitemapConf{Enabled: true, Robots: true, ChangeFreq: "weekly", Disallow: []string{"/private/"}}
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+
+	var urlset sitemapURLSet
+	err = xml.Unmarshal([]byte(readBuilt(t, "sitemap.xml")), &urlset)
+	testerr(err, t)
+	if len(urlset.URLs) != 2 {
+		t.Fatalf("expected two urls, got %+v", urlset.URLs)
+	}
+	home, public := urlset.URLs[0], urlset.URLs[1]
+	if home.Loc != "https://example.org/index.html" || home.LastMod != "2022-03-04T00:00:00Z" || home.ChangeFreq != "weekly" {
+		t.Errorf("unexpected url: %+v", home)
+	}
+	// without a front matter date, the modification time of the source is used
+	if public.Loc != "https://example.org/public/page.html" || public.LastMod == "" {
+		t.Errorf("unexpected url: %+v", public)
+	}
+
+	expected := "User-agent: *\nDisallow: /private/\n\nSitemap: https://example.org/sitemap.xml\n"
+	if robots := readBuilt(t, "robots.txt"); robots != expected {
+		t.Errorf("unexpected robots.txt: %q", robots)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+// IMPORTS
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/yuin/goldmark/ast"
+	gmp "github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+//// SUMMARIES
+// every page gets a short summary, a word count and an estimated reading time, which is useful for listing pages.
+// The summary is taken from the front matter 'summary' or 'description' if there is one.
+// Otherwise, everything above a '<!--more-->' line is used, or if there is none, the first paragraph of the page.
+// -----------------------------------------------------------------------------------
+
+const (
+	SUMMARY_DIVIDER  = "<!--more-->" // a line containing only this marks the end of the summary in a Markdown file
+	WORDS_PER_MINUTE = 200           // the reading speed the reading time is estimated with
+)
+
+// this goldmark AST transformer keeps the summary divider in the rendered html, even if raw html is not
+// rendered (see 'unsafe_rendering'). This way, the summary can be split off after rendering.
+type summaryTransformer struct{}
+
+// Transform implements goldmarks parser.ASTTransformer interface.
+func (t *summaryTransformer) Transform(doc *ast.Document, reader text.Reader, pc gmp.Context) {
+	for node := doc.FirstChild(); node != nil; node = node.NextSibling() {
+		block, ok := node.(*ast.HTMLBlock)
+		if !ok || block.Lines().Len() != 1 {
+			continue
+		}
+		line := block.Lines().At(0)
+		if strings.TrimSpace(string(line.Value(reader.Source()))) != SUMMARY_DIVIDER {
+			continue
+		}
+		// code strings are written as they are, so the divider is neither escaped nor omitted
+		divider := ast.NewString([]byte(SUMMARY_DIVIDER + "\n"))
+		divider.SetCode(true)
+		doc.ReplaceChild(doc, block, divider)
+		return
+	}
+}
+
+// returns the summary of a page, given its front matter and rendered html.
+// a summary from the front matter is plain text, so it is escaped.
+// summaries cut from the html are shown on other pages, so their relative links are made site relative,
+// using the url of the page.
+func extractSummary(params map[string]interface{}, body []byte, page_url string) template.HTML {
+	for _, key := range []string{"summary", "description"} {
+		if summary, ok := params[key].(string); ok && summary != "" {
+			return template.HTML(html.EscapeString(summary))
+		}
+	}
+
+	body = rewriteRelativeLinks(body, page_url, func(site_url string, ref string) string { return site_url })
+	if idx := bytes.Index(body, []byte(SUMMARY_DIVIDER)); idx != -1 {
+		return template.HTML(strings.TrimSpace(string(body[:idx])))
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	paragraph, err := doc.Find("p").First().Html()
+	if err != nil {
+		return ""
+	}
+	return template.HTML(strings.TrimSpace(paragraph))
+}
+
+// returns the text contained in a piece of html, without any tags.
+func plainText(html_content string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html_content))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(doc.Text())
+}
+
+// returns the number of words in the text of the given html.
+func countWords(body []byte) int {
+	return len(strings.Fields(plainText(string(body))))
+}
+
+// returns the estimated number of minutes it takes to read the given number of words, rounded up.
+func readingTime(words int) int {
+	return (words + WORDS_PER_MINUTE - 1) / WORDS_PER_MINUTE
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSummaries(t *testing.T) {
+	long := strings.Repeat("word ", 450)
+	setupWorkspace(t, "{{range .Site.Pages}}{{.Path}}: {{.Summary}} ({{.WordCount}} words, {{.ReadingTime}} min)\n{{end}}{{.Body}}", map[string]string{
+		"described.md": "---\ndescription: Fish & Chips\n---\n# Described\nThe body.",
+		"divided.md":   "# Divided\nIntro with *emphasis*.\n\nMore intro.\n\n<!--more-->\n\nThe rest.",
+		"plain.md":     "# Plain\nFirst paragraph.\n\nSecond paragraph.",
+		"long.md":      long,
+		"empty.md":     "",
+	})
+	testerr(commandBuild(nil), t)
+
+	expected := "/described.md: Fish &amp; Chips (3 words, 1 min)\n" +
+		"/divided.md: <h1>Divided</h1>\n<p>Intro with <em>emphasis</em>.</p>\n<p>More intro.</p> (8 words, 1 min)\n" +
+		"/empty.md:  (0 words, 0 min)\n" +
+		"/long.md: " + strings.TrimSpace(long) + " (450 words, 3 min)\n" +
+		"/plain.md: First paragraph. (5 words, 1 min)\n"
+	if out := readBuilt(t, "plain.html"); !strings.HasPrefix(out, expected) {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	// the divider is kept in the page, even without unsafe rendering
+	if body := readBuilt(t, "divided.html"); !strings.Contains(body, "<p>More intro.</p>\n<!--more-->\n<p>The rest.</p>") {
+		t.Errorf("divider missing: %q", body)
+	}
+}
+
+func TestSummaryLinks(t *testing.T) {
+	setupWorkspace(t, `{{range .Site.Pages}}{{if eq .Path "/blog/post.md"}}{{.Summary}}{{end}}{{end}}`, map[string]string{
+		"index.md":      "",
+		"blog/about.md": "# About",
+		"blog/pic.png":  "pixels",
+		"blog/post.md":  "See [about](about.md#team), ![pic](pic.png), [home](/index.md) and [the web](https://example.org).",
+	})
+	testerr(commandBuild(nil), t)
+
+	// the summary is shown on pages in other directories, so relative links are made site relative
+	expected := `See <a href="/blog/about.html#team">about</a>, <img src="/blog/pic.png" alt="pic"/>, ` +
+		`<a href="/index.html">home</a> and <a href="https://example.org">the web</a>.`
+	if out := readBuilt(t, "index.html"); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+// IMPORTS
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//// TAXONOMIES
+// taxonomies group pages by front matter fields like 'tags' or 'categories'. Each value of such a field is a term.
+// For every taxonomy configured in the global silvera.conf, an index page is generated for each of its terms,
+// listing the pages with that term, as well as an overview page listing all terms.
+// All templates can access the taxonomies through {{.Site.Taxonomies}}.
+// -----------------------------------------------------------------------------------
+
+// this struct holds a single taxonomy, like "tags", with all of its terms.
+type Taxonomy struct {
+	Name  string  // the name of the front matter field, like "tags"
+	URL   string  // the url of the overview page, like "/tags/"
+	Terms []*Term // all terms used by at least one page, sorted by slug
+}
+
+// this struct holds a single term of a taxonomy, like the tag "go".
+type Term struct {
+	Name  string  // the term as written in the front matter
+	Slug  string  // the term in a form usable in urls
+	URL   string  // the url of the index page of the term, like "/tags/go/"
+	Pages []*Page // the pages with this term, newest first
+}
+
+// returns the term with the given name, or nil if no page uses it.
+// this allows templates to link the terms of a page, like {{with .Site.Taxonomies.tags.Term "go"}}{{.URL}}{{end}}.
+func (taxonomy *Taxonomy) Term(name string) *Term {
+	for _, term := range taxonomy.Terms {
+		if term.Name == name || term.Slug == slugify(name) {
+			return term
+		}
+	}
+	return nil
+}
+
+// collects the terms of all configured taxonomies from the front matter of the pages.
+// terms that only differ in case or punctuation (so they share a slug) are merged, using the first spelling seen.
+func buildTaxonomies(config Config, site *Site) map[string]*Taxonomy {
+	taxonomies := make(map[string]*Taxonomy)
+	for _, conf := range config.Taxonomies {
+		taxonomy := &Taxonomy{Name: conf.Name, URL: dirURL(taxonomyOutput(conf))}
+		by_slug := make(map[string]*Term)
+		for _, page := range site.PagesByDate() {
+			for _, name := range stringList(page.Params[conf.Name]) {
+				slug := slugify(name)
+				if slug == "" {
+					continue
+				}
+				term, ok := by_slug[slug]
+				if !ok {
+					term = &Term{Name: name, Slug: slug, URL: taxonomy.URL + slug + "/"}
+					by_slug[slug] = term
+					taxonomy.Terms = append(taxonomy.Terms, term)
+				}
+				if len(term.Pages) == 0 || term.Pages[len(term.Pages)-1] != page { // a page may list a term twice
+					term.Pages = append(term.Pages, page)
+				}
+			}
+		}
+		sort.Slice(taxonomy.Terms, func(i, j int) bool { return taxonomy.Terms[i].Slug < taxonomy.Terms[j].Slug })
+		taxonomies[conf.Name] = taxonomy
+	}
+	return taxonomies
+}
+
+// returns the directory the pages of a taxonomy are written to, relative to the outdir.
+func taxonomyOutput(conf TaxonomyConf) string {
+	if conf.Output != "" {
+		return conf.Output
+	}
+	return conf.Name
+}
+
+// returns the path of a taxonomy template. Relative paths are relative to the workspace.
+// without a configured template, the default template of the site is used.
+func taxonomyTemplate(path string, config Config) string {
+	if path == "" {
+		return config.Templatedir
+	}
+	if !filepath.IsAbs(path) {
+		return filepath.Join(WORKING_DIR, filepath.FromSlash(path))
+	}
+	return path
+}
+
+// returns a simple html list of links, used as the body of the generated pages.
+func linkListHtml(heading string, links [][2]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<h1>%s</h1>\n<ul>\n", html.EscapeString(heading))
+	for _, link := range links {
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString(link[0]), html.EscapeString(link[1]))
+	}
+	b.WriteString("</ul>\n")
+	return b.String()
+}
+
+// returns links to the previous and next page of a listing, if it has more than one page.
+func paginationNavHtml(paginator *Paginator) string {
+	if paginator.Total < 2 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<nav>\n")
+	if paginator.PrevURL != "" {
+		fmt.Fprintf(&b, "<a href=\"%s\">Previous</a>\n", html.EscapeString(paginator.PrevURL))
+	}
+	fmt.Fprintf(&b, "<span>Page %d of %d</span>\n", paginator.Current, paginator.Total)
+	if paginator.NextURL != "" {
+		fmt.Fprintf(&b, "<a href=\"%s\">Next</a>\n", html.EscapeString(paginator.NextURL))
+	}
+	b.WriteString("</nav>\n")
+	return b.String()
+}
+
+// writes the term and overview pages of all taxonomies.
+func writeTaxonomies(config Config, site *Site, data map[string]interface{}, templates *templateSet, report *errorReport) {
+	for _, conf := range config.Taxonomies {
+		taxonomy := site.Taxonomies[conf.Name]
+		if taxonomy == nil || len(taxonomy.Terms) == 0 {
+			continue
+		}
+		out_dir := filepath.Join(config.Outdir, filepath.FromSlash(taxonomyOutput(conf)))
+
+		// the index page of each term, split into pages like any other listing
+		for _, term := range taxonomy.Terms {
+			paginators := paginate(term.Pages, pageSize(config.Pagination), term.URL, filepath.Join(out_dir, term.Slug, "index.html"))
+			for _, paginator := range paginators {
+				var links [][2]string
+				for _, page := range paginator.Items {
+					links = append(links, [2]string{page.URL, page.Title})
+				}
+				contents := EmbeddableContents{
+					Title:     term.Name,
+					Body:      template.HTML(linkListHtml(term.Name, links) + paginationNavHtml(paginator)),
+					Path:      paginator.URL,
+					URL:       paginator.URL,
+					Params:    map[string]interface{}{},
+					Site:      site,
+					Data:      data,
+					Taxonomy:  taxonomy,
+					Term:      term,
+					Paginator: paginator,
+				}
+				writeGeneratedPage(paginator.outPath, taxonomyTemplate(conf.Template, config), contents, templates, report)
+			}
+		}
+
+		// the overview page, listing all terms
+		var links [][2]string
+		for _, term := range taxonomy.Terms {
+			links = append(links, [2]string{term.URL, fmt.Sprintf("%s (%d)", term.Name, len(term.Pages))})
+		}
+		contents := EmbeddableContents{
+			Title:    titleCase(conf.Name),
+			Body:     template.HTML(linkListHtml(titleCase(conf.Name), links)),
+			Path:     taxonomy.URL,
+			URL:      taxonomy.URL,
+			Params:   map[string]interface{}{},
+			Site:     site,
+			Data:     data,
+			Taxonomy: taxonomy,
+		}
+		writeGeneratedPage(filepath.Join(out_dir, "index.html"), taxonomyTemplate(conf.ListTemplate, config), contents, templates, report)
+	}
+}
+
+// embeds the contents of a page that has no source file in a template, and writes it to out_path.
+func writeGeneratedPage(out_path string, template_path string, contents EmbeddableContents, templates *templateSet, report *errorReport) {
+	out, err := executeTemplate(template_path, contents, templates)
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(out_path), 0755); err == nil {
+			err = ioutil.WriteFile(out_path, out, 0644)
+		}
+	}
+	if err != nil {
+		report.add(out_path, err)
+		return
+	}
+	fmt.Println("generated:", contents.Path, "->", out_path)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTaxonomies(t *testing.T) {
+	setupWorkspace(t, `{{.Title}}:{{range .Params.tags}}{{with $.Site.Taxonomies.tags.Term .}}<a href="{{.URL}}">{{.Name}}</a>{{end}}{{end}}`, map[string]string{
+		"old.md":   "---\ntitle: Old\ndate: 2020-01-01\ntags: [Go, web]\ncategories: news\n---\n",
+		"new.md":   "---\ntitle: New\ndate: 2022-01-01\ntags: [go, tools]\n---\n",
+		"other.md": "# Other",
+	})
+	testerr(os.WriteFile(filepath.Join(WORKING_DIR, "term.html"), []byte(`{{.Term.Name}} in {{.Taxonomy.Name}}:{{range .Term.Pages}}{{.Title}};{{end}}`), 0644), t)
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	if len(conf.Taxonomies) != 2 {
+		t.Fatalf("expected tags and categories by default, got %v", conf.Taxonomies)
+	}
+	conf.Taxonomies[0].Template = "term.html"
+	conf.Taxonomies[1].Output = "sections"
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+
+	expected := map[string]string{
+		// pages can link to the terms they use. Terms are merged by their slug,
+		// and keep the spelling of the newest page
+		"old.html": `Old:<a href="/tags/go/">go</a><a href="/tags/web/">web</a>`,
+		// term pages list their pages newest first
+		"tags/go/index.html":    "go in tags:New;Old;",
+		"tags/tools/index.html": "tools in tags:New;",
+		// without a configured template, the default template is used with a generated list
+		"tags/index.html":          "Tags:",
+		"sections/news/index.html": "news:",
+		"sections/index.html":      "Categories:",
+	}
+	for name, out := range expected {
+		if built := readBuilt(t, name); built != out {
+			t.Errorf("%s: expected %q, got %q", name, out, built)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+// IMPORTS
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+//// TEMPLATES
+// pages are embedded into their templates using golangs html/template package, which escapes
+// the embedded values according to where in the html they appear. The body of a page is already html,
+// so it is marked as safe and embedded as it is.
+// The templates in the workspaces 'partials' directory are parsed once per build, and can be used from
+// every page template, like {{template "header" .}}. A partial can also serve as a base layout,
+// with {{block "main" .}} sections that the page templates fill in using {{define "main"}}.
+// Which template a page uses can be chosen per page, see pageTemplate.
+// -----------------------------------------------------------------------------------
+
+// this struct holds the templates used during a single build. It may be used from multiple goroutines at once.
+type templateSet struct {
+	mutex    sync.Mutex
+	partials *template.Template            // the partials, which every page template is based on
+	parsed   map[string]*template.Template // the page templates parsed so far, by their path
+	errs     map[string]error              // the errors of page templates that could not be parsed, by their path
+	hash     string                        // a hash of all partials, so the build cache notices when one changes
+}
+
+// parses all templates found below the given partials directory. A missing directory simply means there are no partials.
+// the template functions are set up using the given (global) config.
+// each partial is named after its path relative to the directory, without the extension,
+// so 'partials/header.html' becomes "header", and 'partials/nav/main.html' becomes "nav/main".
+func loadTemplates(partials_dir string, config Config) (*templateSet, error) {
+	set := &templateSet{
+		partials: template.New("").Funcs(templateFuncs(config)),
+		parsed:   make(map[string]*template.Template),
+		errs:     make(map[string]error),
+		hash:     hashDir(partials_dir),
+	}
+	err := filepath.Walk(partials_dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == partials_dir {
+				return nil
+			}
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") || info.IsDir() {
+			return nil
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return &BuildError{Path: path, Err: err}
+		}
+		rel, _ := filepath.Rel(partials_dir, path)
+		name := filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))
+		if _, err := set.partials.New(name).Parse(string(contents)); err != nil {
+			return &BuildError{Path: path, Err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// returns the parsed page template at the given path, together with all partials.
+// each page template is only parsed once, and gets its own copy of the partials,
+// so the blocks it defines don't affect other page templates.
+func (set *templateSet) get(path string) (*template.Template, error) {
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	if tmpl, ok := set.parsed[path]; ok {
+		return tmpl, nil
+	}
+	if err, ok := set.errs[path]; ok {
+		return nil, err
+	}
+
+	tmpl, err := set.parse(path)
+	if err != nil {
+		set.errs[path] = err
+		return nil, err
+	}
+	set.parsed[path] = tmpl
+	return tmpl, nil
+}
+
+func (set *templateSet) parse(path string) (*template.Template, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := set.partials.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.New(filepath.Base(path)).Parse(string(contents))
+}
+
+// returns the path of the template the page at src_path is embedded in. In order of precedence, this is:
+//   - the template named in the front matter, like 'template: post.html'. It is searched in the '.slv' directories of
+//     the page and its ancestors, then next to the template of the effective config, and then in the workspace.
+//   - a template named after the page in the '.slv' directory next to it, like 'blog/.slv/about.html' for 'blog/about.md'.
+//   - the template of the effective config.
+func pageTemplate(src_path string, params map[string]interface{}, conf Config) (string, error) {
+	if name, ok := params["template"].(string); ok && name != "" {
+		if filepath.IsAbs(name) {
+			return name, nil
+		}
+		var dirs []string
+		for dir := filepath.Dir(src_path); ; dir = filepath.Dir(dir) {
+			dirs = append(dirs, filepath.Join(dir, HIDDEN_DIR))
+			if dir == filepath.Clean(SOURCE_DIR) || dir == filepath.Dir(dir) {
+				break
+			}
+		}
+		dirs = append(dirs, filepath.Dir(conf.Templatedir), WORKING_DIR)
+		for _, dir := range dirs {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+		return "", fmt.Errorf("template %q not found", name)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(src_path), ".md") + ".html"
+	path := filepath.Join(filepath.Dir(src_path), HIDDEN_DIR, name)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	return conf.Templatedir, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplates(t *testing.T) {
+	setupWorkspace(t, `{{template "base" .}}{{define "main"}}<article>{{.Body}}</article>{{end}}`, map[string]string{
+		"index.md":                 "---\ntitle: Fish & <Chips>\n---\n# Home",
+		"plain/page.md":            "# Plain",
+		"plain/.slv/template.html": `{{template "base" .}}`,
+	})
+	// the local config depends on the workspace path, so it is written after the setup
+	err := os.WriteFile(filepath.Join(SOURCE_DIR, "plain/.slv/silvera.conf"), []byte("template: "+filepath.Join(SOURCE_DIR, "plain/.slv/template.html")), 0644)
+	testerr(err, t)
+	testerr(os.MkdirAll(filepath.Join(PARTIALS_DIR, "nav"), 0755), t)
+	partials := map[string]string{
+		"base.html":      `<title>{{.Title}}</title>{{template "nav/links" .}}{{block "main" .}}<div>{{.Body}}</div>{{end}}`,
+		"nav/links.html": `<nav>{{range .Site.Pages}}<a href="{{.URL}}">{{.Title}}</a>{{end}}</nav>`,
+	}
+	for name, contents := range partials {
+		testerr(os.WriteFile(filepath.Join(PARTIALS_DIR, filepath.FromSlash(name)), []byte(contents), 0644), t)
+	}
+	testerr(commandBuild(nil), t)
+
+	nav := `<nav><a href="/index.html">Fish &amp; &lt;Chips&gt;</a><a href="/plain/page.html">Plain</a></nav>`
+	expected := map[string]string{
+		"index.html":      `<title>Fish &amp; &lt;Chips&gt;</title>` + nav + `<article><h1>Home</h1>` + "\n</article>",
+		"plain/page.html": `<title>Plain</title>` + nav + `<div><h1>Plain</h1>` + "\n</div>",
+	}
+	for name, out := range expected {
+		if built := readBuilt(t, name); built != out {
+			t.Errorf("%s: expected %q, got %q", name, out, built)
+		}
+	}
+
+	// a broken partial stops the build
+	testerr(os.WriteFile(filepath.Join(PARTIALS_DIR, "broken.html"), []byte("{{if}}"), 0644), t)
+	var build_err *BuildError
+	if err := commandBuild(nil); !errors.As(err, &build_err) || filepath.Base(build_err.Path) != "broken.html" {
+		t.Errorf("expected an error for the broken partial, got %v", err)
+	}
+}
+
+func TestPageTemplates(t *testing.T) {
+	setupWorkspace(t, "default:{{.Title}}", map[string]string{
+		"index.md":             "# Home",
+		"blog/post.md":         "---\ntemplate: post.html\n---\n# Post",
+		"blog/deep/post.md":    "---\ntemplate: post.html\n---\n# Deep Post",
+		"blog/about.md":        "# About",
+		"blog/other.md":        "---\ntemplate: layouts/wide.html\n---\n# Other",
+		"blog/missing.md":      "---\ntemplate: nope.html\n---\n# Missing",
+		"blog/.slv/post.html":  "post:{{.Title}}",
+		"blog/.slv/about.html": "about:{{.Title}}",
+		"blog/deep/other.md":   "---\ntemplate: template.html\n---\n# Deep Other",
+	})
+	testerr(os.MkdirAll(filepath.Join(WORKING_DIR, "layouts"), 0755), t)
+	testerr(os.WriteFile(filepath.Join(WORKING_DIR, "layouts", "wide.html"), []byte("wide:{{.Title}}"), 0644), t)
+
+	var errs BuildErrors
+	if err := commandBuild(nil); !errors.As(err, &errs) || len(errs) != 1 || filepath.Base(errs[0].Path) != "missing.md" {
+		t.Errorf("expected an error for the missing template, got %v", err)
+	}
+	expected := map[string]string{
+		"index.html":           "default:Home",
+		"blog/post.html":       "post:Post",          // from the front matter, found in the .slv dir next to it
+		"blog/deep/post.html":  "post:Deep Post",     // found in the .slv dir of an ancestor
+		"blog/about.html":      "about:About",        // named after the page
+		"blog/other.html":      "wide:Other",         // found in the workspace
+		"blog/deep/other.html": "default:Deep Other", // found next to the default template
+	}
+	for name, out := range expected {
+		if built := readBuilt(t, name); built != out {
+			t.Errorf("%s: expected %q, got %q", name, out, built)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+// IMPORTS
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+//// TABLE OF CONTENTS
+// the headings of every page are collected into a table of contents, which templates can access as {{.TOC}}.
+// Unlike the 'table_of_contents' extension, which places the TOC inside the body, this allows putting it anywhere,
+// like in a sidebar. Which heading levels are listed is configured in the 'toc' section of 'silvera.conf'.
+// The headings need ids to be linked, so 'auto_heading_id' should be enabled.
+// -----------------------------------------------------------------------------------
+
+// this struct holds the table of contents of a page.
+type TOC struct {
+	HTML  template.HTML // the table of contents as nested html lists, ready to be embedded
+	Items []*TOCItem    // the top level headings, each holding the headings below it
+}
+
+// this struct holds a single heading in the table of contents.
+type TOCItem struct {
+	Title    string     // the text of the heading
+	ID       string     // the id of the heading, empty if it has none
+	Level    int        // the level of the heading, from 1 for h1 to 6 for h6
+	Children []*TOCItem // the headings nested below this one
+}
+
+// returns the lowest and highest heading level listed with the given config.
+// values that are not set (or out of range) default to listing all levels.
+func tocDepth(conf TOCConf) (int, int) {
+	min_depth, max_depth := conf.MinDepth, conf.MaxDepth
+	if min_depth < 1 || min_depth > 6 {
+		min_depth = 1
+	}
+	if max_depth < min_depth || max_depth > 6 {
+		max_depth = 6
+	}
+	return min_depth, max_depth
+}
+
+// collects the headings of the given html into a table of contents.
+// a heading becomes a child of the nearest heading above it with a lower level.
+func buildTOC(body []byte, conf TOCConf) *TOC {
+	toc := &TOC{}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return toc
+	}
+
+	min_depth, max_depth := tocDepth(conf)
+	var selectors []string
+	for level := min_depth; level <= max_depth; level++ {
+		selectors = append(selectors, fmt.Sprintf("h%d", level))
+	}
+
+	var parents []*TOCItem // the chain of headings the next heading may be nested in
+	doc.Find(strings.Join(selectors, ", ")).Each(func(i int, heading *goquery.Selection) {
+		id, _ := heading.Attr("id")
+		item := &TOCItem{
+			Title: strings.TrimSpace(heading.Text()),
+			ID:    id,
+			Level: int(goquery.NodeName(heading)[1] - '0'),
+		}
+		for len(parents) > 0 && parents[len(parents)-1].Level >= item.Level {
+			parents = parents[:len(parents)-1]
+		}
+		if len(parents) == 0 {
+			toc.Items = append(toc.Items, item)
+		} else {
+			parent := parents[len(parents)-1]
+			parent.Children = append(parent.Children, item)
+		}
+		parents = append(parents, item)
+	})
+
+	if len(toc.Items) > 0 {
+		var b strings.Builder
+		writeTOCList(&b, toc.Items)
+		toc.HTML = template.HTML(b.String())
+	}
+	return toc
+}
+
+// writes the given items as a nested html list. headings without an id are listed, but not linked.
+func writeTOCList(b *strings.Builder, items []*TOCItem) {
+	b.WriteString("<ul>\n")
+	for _, item := range items {
+		if item.ID != "" {
+			fmt.Fprintf(b, "<li><a href=\"#%s\">%s</a>", html.EscapeString(item.ID), html.EscapeString(item.Title))
+		} else {
+			fmt.Fprintf(b, "<li>%s", html.EscapeString(item.Title))
+		}
+		if len(item.Children) > 0 {
+			b.WriteString("\n")
+			writeTOCList(b, item.Children)
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ul>\n")
+}
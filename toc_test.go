@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTOC(t *testing.T) {
+	setupWorkspace(t, `{{.TOC.HTML}}{{range .TOC.Items}}{{.Level}}:{{.ID}}[{{range .Children}}{{.Level}}:{{.ID}};{{end}}]{{end}}`, map[string]string{
+		"index.md": "# Title\n## Intro\n### Details\n#### Too Deep\n## Usage & More\n### Install\n",
+		"skip.md":  "# Title\n### Skipped a Level",
+	})
+	conf, err := readConfigFile(filepath.Join(WORKING_DIR, "silvera.conf"), Config{})
+	testerr(err, t)
+	conf.ParserOptions.WithAutoHeadingID = true
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+
+	// by default, only h2 and h3 headings are listed
+	expected := "<ul>\n" +
+		"<li><a href=\"#intro\">Intro</a>\n<ul>\n<li><a href=\"#details\">Details</a></li>\n</ul>\n</li>\n" +
+		"<li><a href=\"#usage--more\">Usage &amp; More</a>\n<ul>\n<li><a href=\"#install\">Install</a></li>\n</ul>\n</li>\n" +
+		"</ul>\n" +
+		"2:intro[3:details;]2:usage--more[3:install;]"
+	if out := readBuilt(t, "index.html"); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+	if out := readBuilt(t, "skip.html"); out != "<ul>\n<li><a href=\"#skipped-a-level\">Skipped a Level</a></li>\n</ul>\n3:skipped-a-level[]" {
+		t.Errorf("unexpected TOC: %q", out)
+	}
+
+	// without ids, the headings are still listed, but not linked.
+	// levels that are skipped don't break the nesting.
+	conf.ParserOptions.WithAutoHeadingID = false
+	conf.TOC = TOCConf{MinDepth: 1, MaxDepth: 4}
+	writeConfig(t, conf)
+	testerr(commandBuild(nil), t)
+	expected = "<ul>\n<li>Title\n<ul>\n" +
+		"<li>Intro\n<ul>\n<li>Details\n<ul>\n<li>Too Deep</li>\n</ul>\n</li>\n</ul>\n</li>\n" +
+		"<li>Usage &amp; More\n<ul>\n<li>Install</li>\n</ul>\n</li>\n" +
+		"</ul>\n</li>\n</ul>\n" +
+		"1:[2:;2:;]"
+	if out := readBuilt(t, "index.html"); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+	if out := readBuilt(t, "skip.html"); out != "<ul>\n<li>Title\n<ul>\n<li>Skipped a Level</li>\n</ul>\n</li>\n</ul>\n1:[3:;]" {
+		t.Errorf("unexpected TOC: %q", out)
+	}
+}